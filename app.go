@@ -27,6 +27,7 @@ import (
 	"github.com/CloudyKit/cloudy/link"
 	"github.com/CloudyKit/cloudy/registry"
 	"github.com/CloudyKit/router"
+	"log/slog"
 	"net/http"
 	"os"
 	"reflect"
@@ -43,7 +44,7 @@ func GetKernel(c Registry) *Kernel {
 var DefaultKernel = NewKernel()
 
 func NewKernel() *Kernel {
-	kernel := &Kernel{Registry: registry.New(), Router: router.New(), URLGen: make(MapURLGen), emitter: event.NewDispatcher()}
+	kernel := &Kernel{Registry: registry.New(), Router: router.New(), URLGen: make(MapURLGen), emitter: event.NewDispatcher(), routes: new(routeTable), hosts: new(hostTable), groups: new(groupTable)}
 
 	// provide service URLGen as URLer
 	kernel.Registry.WithTypeAndValue(link.URLGenType, kernel.URLGen)
@@ -52,6 +53,10 @@ func NewKernel() *Kernel {
 	// provide the app
 	kernel.Registry.WithTypeAndValue(KernelType, kernel)
 	kernel.Registry.WithTypeAndValue(event.EmitterType, kernel.emitter)
+	// provide the structured logger, slog.Default is loaded when used, this way slog.SetDefault is respected
+	kernel.Registry.WithTypeAndProviderFunc(SlogType, func(Registry) interface{} {
+		return slog.Default()
+	})
 
 	return kernel
 }
@@ -103,11 +108,20 @@ type emitter interface {
 //	Router, Dispatcher, Scope
 type Kernel struct {
 	emitter emitter
+	routes  *routeTable
+	hosts   *hostTable
 
-	Registry Registry       // Kernel Registry dependency injection context
-	Router   *router.Router // Router
-	Prefix   string         // Prefix prefix for path added in this app
-	URLGen   MapURLGen
+	notFound         Handler
+	methodNotAllowed Handler
+
+	parentNamePrefix string      // parentNamePrefix is the name prefix of the kernel creating the group, see Group
+	groups           *groupTable // groups created by the kernel, disposed with the kernel
+
+	Registry   Registry       // Kernel Registry dependency injection context
+	Router     *router.Router // Router
+	Prefix     string         // Prefix prefix for path added in this app
+	NamePrefix string         // NamePrefix prefix for the url names added in this app, ex: "admin.", groups add it after the prefix of the kernel, see Group
+	URLGen     MapURLGen
 	MiddlewareBundle
 }
 
@@ -130,6 +144,7 @@ func (kernel *Kernel) Snapshot() *Kernel {
 
 	newKernel.Registry = kernel.Registry.Fork()
 	newKernel.Registry.WithTypeAndValue(KernelType, newKernel)
+	newKernel.groups = new(groupTable)
 
 	return &newKernel
 }
@@ -162,9 +177,12 @@ func (kernel *Kernel) AddComponents(b ...Component) {
 	}
 }
 
-// Dispose Close same as app.registry.Close() invoke this func before exiting the app to cleanup
+// Dispose Close same as app.registry.Close() invoke this func before exiting the app to cleanup,
+// the registries of the groups of the kernel are disposed first
 func (kernel *Kernel) Dispose() {
-	kernel.Registry.Dispose()
+	if kernel.groups.dispose() {
+		kernel.Registry.Dispose()
+	}
 }
 
 // AddHandlerFunc register a func handler, see: Handler
@@ -187,6 +205,12 @@ func (kernel *Kernel) AddHandlerName(name, method, path string, handler Handler,
 //
 //	multiples handles app.AddHandlerContextName(myContext,"mySectionIdentifier","GET|POST|SEARCH", "/products",productHandler,checkAuth)
 func (kernel *Kernel) AddHandlerContextName(registry Registry, name, method, path string, handler Handler, filters ...Handler) {
+	kernel.addRoute(registry, name, name, method, path, handler, filters...)
+}
+
+// addRoute adds the route into the router and into the route table, routeName is the name
+// listed in the route table, name is the name passed to the Context
+func (kernel *Kernel) addRoute(registry Registry, name, routeName, method, path string, handler Handler, filters ...Handler) {
 
 	filters = append(kernel.reSlice(filters...), handler)
 
@@ -195,9 +219,20 @@ func (kernel *Kernel) AddHandlerContextName(registry Registry, name, method, pat
 	}
 
 	for _, method := range strings.Split(method, "|") {
+		route := kernel.routes.add(method, kernel.Prefix+path, routeName, filters)
 		kernel.Router.AddRoute(method, kernel.Prefix+path, func(rw http.ResponseWriter, r *http.Request, v router.Parameter) {
+			if lookup, ok := rw.(*routeLookup); ok {
+				lookup.route, lookup.found = route, true
+				return
+			}
 			c := newRequestContext()
 			defer requestRecover(c)
+			c.route = registry
+			c.Pattern = kernel.Prefix + path
+			// the pattern of a route of a mounted kernel includes the mount path, see Kernel.Mount
+			if mountPath, _ := r.Context().Value(mountPathKey{}).(string); mountPath != "" {
+				c.Pattern = mountPath + c.Pattern
+			}
 			_ = DispatchNext(c, name, rw, r, v, registry.Fork(), filters)
 		})
 	}
@@ -207,6 +242,7 @@ func (kernel *Kernel) AddHandlerContextName(registry Registry, name, method, pat
 func requestRecover(c *Context) {
 
 	variables := c.Registry
+	c.releaseBody()
 	// resets request context
 	*c = Context{}
 	contextPool.Put(c)
@@ -235,7 +271,8 @@ func (kernel *Kernel) host(host string) (servein string) {
 func (kernel *Kernel) RunServer(host string) error {
 	//e := &RunServerEvent{Host: host}
 	//kernel.Dispatch("hub.run", e)
-	return http.ListenAndServe(host, kernel.Router)
+	kernel.RoutesHook()
+	return http.ListenAndServe(host, kernel)
 }
 
 // RunServerTLS runs the server in tls mode
@@ -243,7 +280,8 @@ func (kernel *Kernel) RunServer(host string) error {
 func (kernel *Kernel) RunServerTLS(host, certfile, keyfile string) error {
 	//e := &RunServerEventTLS{Host: host, CertFile: certfile, KeyFile: keyfile}
 	// kernel.Dispatch("hub.run.tls", e)
-	return http.ListenAndServeTLS(kernel.host(host), certfile, keyfile, kernel.Router)
+	kernel.RoutesHook()
+	return http.ListenAndServeTLS(kernel.host(host), certfile, keyfile, kernel)
 }
 
 func (kernel *Kernel) Subscribe(eventName string, handler interface{}) {
@@ -254,6 +292,72 @@ func (kernel *Kernel) Dispatch(eventName string, payload event.Payload) {
 	_, _ = kernel.emitter.Dispatch(kernel.Registry, eventName, payload)
 }
 
+// Group creates a child kernel where the routes are added under prefix and run the middlewares
+// of the kernel plus the group middlewares, fn receives the group kernel, groups can be nested.
+// The group gets its own registry forked from the kernel registry, components added to the group
+// are scoped to the group, as the middlewares and values they register, the registry is disposed
+// with the kernel. The group NamePrefix is added after the name prefix of the kernel, ex: admin
+// names are "api.admin.Users.Show" in a group of a kernel with NamePrefix "api.".
+//
+//	kernel.Group("/admin", func(admin *cloudy.Kernel) {
+//		admin.NamePrefix = "admin."
+//		admin.AddControllers(&Users{})
+//	}, authMiddleware)
+func (kernel *Kernel) Group(prefix string, fn func(group *Kernel), middlewares ...Handler) *Kernel {
+	group := kernel.Fork()
+	group.Prefix = kernel.Prefix + prefix
+	group.parentNamePrefix = kernel.namePrefix()
+	group.NamePrefix = ""
+	group.AddMiddleware(middlewares...)
+
+	group.Registry = kernel.Registry.Fork()
+	group.Registry.WithTypeAndValue(KernelType, group)
+	group.groups = new(groupTable)
+	kernel.groups.add(group)
+
+	if fn != nil {
+		fn(group)
+	}
+	return group
+}
+
+// namePrefix returns the prefix of the url names added by the kernel, the name prefix of the
+// kernel creating the group followed by NamePrefix
+func (kernel *Kernel) namePrefix() string {
+	return kernel.parentNamePrefix + kernel.NamePrefix
+}
+
+// groupTable holds the groups of a kernel, forks of the kernel share the table as they share
+// the registry
+type groupTable struct {
+	mx       sync.Mutex
+	groups   []*Kernel
+	disposed bool
+}
+
+func (table *groupTable) add(group *Kernel) {
+	table.mx.Lock()
+	defer table.mx.Unlock()
+	table.groups = append(table.groups, group)
+}
+
+// dispose disposes the groups and reports if the registry of the kernel should be disposed,
+// the kernel is a value of its registry, the registry disposes the kernel again while finalizing
+func (table *groupTable) dispose() bool {
+	if table == nil {
+		return true
+	}
+	table.mx.Lock()
+	groups, disposed := table.groups, table.disposed
+	table.groups, table.disposed = nil, true
+	table.mx.Unlock()
+
+	for _, group := range groups {
+		group.Dispose()
+	}
+	return !disposed
+}
+
 // Fork create child app
 func (kernel *Kernel) Fork() *Kernel {
 	newApp := *kernel
@@ -263,7 +367,9 @@ func (kernel *Kernel) Fork() *Kernel {
 }
 
 func (kernel *Kernel) MustDispose() {
-	kernel.Registry.MustDispose()
+	if kernel.groups.dispose() {
+		kernel.Registry.MustDispose()
+	}
 }
 
 var contextPool = sync.Pool{
@@ -0,0 +1,218 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// BodyBufferSize is the size of the request bodies kept in memory once a handler asks to re-read
+// the body with GetBodyBytes or GetBodyReader, larger bodies are spooled to a temporary file
+var BodyBufferSize int64 = 1 << 20
+
+// ErrBodyConsumed is returned when the body is buffered after being read from Request.Body
+var ErrBodyConsumed = errors.New("cloudy: request body was streamed before being buffered")
+
+// requestBody streams the body of the request, the bytes read count against the BodyLimit
+type requestBody struct {
+	c *Context
+}
+
+func (body requestBody) Read(p []byte) (int, error) {
+	return body.c.readBody(p)
+}
+
+func (body requestBody) Close() error {
+	if body.c.body == nil {
+		return nil
+	}
+	return body.c.body.Close()
+}
+
+// readBody reads from the body of the request, an error other than io.EOF is returned again
+// by the next reads
+func (c *Context) readBody(p []byte) (int, error) {
+	if c.bodyErr != nil {
+		return 0, c.bodyErr
+	}
+	if c.body == nil {
+		return 0, io.EOF
+	}
+
+	limit := c.bodyLimit
+	if limit > 0 && int64(len(p)) > limit-c.bodyRead+1 {
+		// reads one byte past the limit to tell a body of exactly limit bytes from a larger one
+		p = p[:limit-c.bodyRead+1]
+	}
+
+	n, err := c.body.Read(p)
+	c.bodyRead += int64(n)
+	if limit > 0 && c.bodyRead > limit {
+		n -= int(c.bodyRead - limit)
+		c.bodyRead = limit
+		err = &http.MaxBytesError{Limit: limit}
+	}
+	if err != nil && err != io.EOF {
+		c.bodyErr = err
+	}
+	return n, err
+}
+
+// bodySpool keeps the bytes read from the body for replays, in memory up to BodyBufferSize
+type bodySpool struct {
+	memory []byte
+	file   *os.File
+	size   int64
+	done   bool
+}
+
+// bufferBody starts keeping the body for replays, from now on Request.Body replays the body
+func (c *Context) bufferBody() error {
+	if c.spool != nil {
+		return nil
+	}
+	if c.bodyRead > 0 {
+		return ErrBodyConsumed
+	}
+	c.spool = &bodySpool{}
+	if _, streaming := c.Request.Body.(requestBody); streaming {
+		c.Request.Body = &bodyReplay{c: c}
+	}
+	return nil
+}
+
+// readSpool reads the body at offset, bytes not yet in the spool are read from the request
+func (c *Context) readSpool(p []byte, offset int64) (int, error) {
+	spool := c.spool
+	if offset < spool.size {
+		if remaining := spool.size - offset; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		if spool.file != nil {
+			return spool.file.ReadAt(p, offset)
+		}
+		return copy(p, spool.memory[offset:]), nil
+	}
+	if spool.done {
+		return 0, io.EOF
+	}
+
+	n, err := c.readBody(p)
+	if n > 0 {
+		if writeErr := c.writeSpool(p[:n]); writeErr != nil {
+			return 0, writeErr
+		}
+	}
+	if err == io.EOF {
+		spool.done = true
+	}
+	return n, err
+}
+
+func (c *Context) writeSpool(b []byte) error {
+	spool := c.spool
+	if spool.file == nil && spool.size+int64(len(b)) > BodyBufferSize {
+		file, err := os.CreateTemp("", "cloudy-body-*")
+		if err != nil {
+			return err
+		}
+		spool.file = file
+		if _, err := file.Write(spool.memory); err != nil {
+			return err
+		}
+		spool.memory = nil
+	}
+
+	if spool.file != nil {
+		if _, err := spool.file.Write(b); err != nil {
+			return err
+		}
+	} else {
+		spool.memory = append(spool.memory, b...)
+	}
+	spool.size += int64(len(b))
+	return nil
+}
+
+// releaseBody removes the temporary file of the spool
+func (c *Context) releaseBody() {
+	if c.spool != nil && c.spool.file != nil {
+		c.spool.file.Close()
+		os.Remove(c.spool.file.Name())
+	}
+}
+
+// bodyReplay reads the body from the start, bytes are kept in the spool as they are read
+type bodyReplay struct {
+	c      *Context
+	offset int64
+}
+
+func (replay *bodyReplay) Read(p []byte) (int, error) {
+	if err := replay.c.bufferBody(); err != nil {
+		return 0, err
+	}
+	n, err := replay.c.readSpool(p, replay.offset)
+	replay.offset += int64(n)
+	return n, err
+}
+
+// Close keeps the body available to other replays
+func (replay *bodyReplay) Close() error {
+	return nil
+}
+
+// GetBodyBytes returns the whole body of the request, the body is kept and can be read again
+// with GetBodyReader, for large bodies prefer GetBodyReader as the bytes are loaded in memory
+func (c *Context) GetBodyBytes() ([]byte, error) {
+	if err := c.bufferBody(); err != nil {
+		return nil, err
+	}
+	spool := c.spool
+	if spool.done && spool.file == nil {
+		return spool.memory, nil
+	}
+	return io.ReadAll(&bodyReplay{c: c})
+}
+
+// bindBody returns the body decoded by the binds, the body is streamed unless a handler asked
+// to replay it, then the binds decode it from the start
+func (c *Context) bindBody() io.ReadCloser {
+	if c.spool != nil {
+		return c.GetBodyReader()
+	}
+	if c.Request.Body == nil {
+		return http.NoBody
+	}
+	return c.Request.Body
+}
+
+// GetBodyReader returns a reader of the body from the start, the reader can be created many times,
+// ex: a middleware peeks the body and BindJSON decodes it again. The body is streamed while nothing
+// asks for it, once read from Request.Body it can't be replayed and the reader returns ErrBodyConsumed.
+func (c *Context) GetBodyReader() io.ReadCloser {
+	return &bodyReplay{c: c}
+}
@@ -0,0 +1,150 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestContext_StreamBody(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("POST", "/stream", func(c *Context) {
+		if _, streaming := c.Request.Body.(requestBody); !streaming {
+			t.Errorf("unexpected body %T", c.Request.Body)
+		}
+		head := make([]byte, 3)
+		io.ReadFull(c.Request.Body, head)
+		if c.spool != nil {
+			t.Error("the body was buffered without being asked")
+		}
+		if _, err := c.GetBodyBytes(); !errors.Is(err, ErrBodyConsumed) {
+			t.Errorf("expected ErrBodyConsumed, got %v", err)
+		}
+		rest, _ := io.ReadAll(c.Request.Body)
+		c.Printf("%s|%s", head, rest)
+	})
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("POST", "/stream", strings.NewReader("abcdef")))
+	if body := recorder.Body.String(); body != "abc|def" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestContext_BindStreamsBody(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("POST", "/orders", func(c *Context) {
+		var order struct{ Item string }
+		if err := c.BindJSON(&order); err != nil {
+			t.Fatal(err)
+		}
+		if c.spool != nil {
+			t.Error("BindJSON buffered the body without being asked")
+		}
+		c.WriteString(order.Item)
+	})
+	kernel.AddHandlerFunc("POST", "/signup", func(c *Context) {
+		var form struct{ Name string }
+		if err := c.BindForm(&form); err != nil {
+			t.Fatal(err)
+		}
+		if c.spool != nil {
+			t.Error("BindForm buffered the body without being asked")
+		}
+		c.WriteString(form.Name)
+	})
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"Item":"book"}`)))
+	if body := recorder.Body.String(); body != "book" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	request := httptest.NewRequest("POST", "/signup", strings.NewReader("Name=ana"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, request)
+	if body := recorder.Body.String(); body != "ana" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestContext_ReplayBody(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddMiddlewareFunc(func(c *Context) {
+		// peeks the first bytes, the handler still reads the whole body
+		peek := make([]byte, 8)
+		n, _ := io.ReadFull(c.GetBodyReader(), peek)
+		c.Response.Header().Set("X-Peek", string(peek[:n]))
+		c.Next()
+	})
+	kernel.AddHandlerFunc("POST", "/orders", func(c *Context) {
+		var order struct{ Item string }
+		if err := c.BindJSON(&order); err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(c.Request.Body)
+		c.Printf("%s %d", order.Item, len(raw))
+	})
+
+	body := `{"Item":"book"}`
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("POST", "/orders", strings.NewReader(body)))
+	if recorder.Header().Get("X-Peek") != body[:8] || recorder.Body.String() != "book 15" {
+		t.Errorf("unexpected response %q %q", recorder.Header().Get("X-Peek"), recorder.Body.String())
+	}
+}
+
+func TestContext_SpoolBody(t *testing.T) {
+	defer func(size int64) { BodyBufferSize = size }(BodyBufferSize)
+	BodyBufferSize = 4
+
+	var spooled string
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("POST", "/upload", func(c *Context) {
+		first, err := c.GetBodyBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.spool.file == nil {
+			t.Fatal("expected the body to be spooled to a file")
+		}
+		spooled = c.spool.file.Name()
+		second, _ := io.ReadAll(c.GetBodyReader())
+		c.Printf("%s|%s", first, second)
+	})
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789")))
+	if body := recorder.Body.String(); body != "0123456789|0123456789" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if _, err := os.Stat(spooled); !os.IsNotExist(err) {
+		t.Errorf("the spool file was not removed, %v", err)
+	}
+}
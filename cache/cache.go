@@ -0,0 +1,587 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package cache keeps the responses of idempotent routes in a Store, responses are keyed by the
+// method, the host, the path, the query and selected headers, and by the headers named in the Vary
+// of the response.
+//
+//	kernel.AddComponents(&cache.Component{})
+//
+//	func (c *Products) Mx(mx *cloudy.Mapper) {
+//		mx.BindAction("GET", "/products/:id", "Show", &cache.Policy{TTL: time.Minute, StaleWhileRevalidate: time.Hour})
+//		mx.BindAction("PUT", "/products/:id", "Update")
+//	}
+//
+//	func (c *Products) Show() {
+//		cache.Tag(c.Context.Registry, "product:"+c.Context.GetURLParameter("id"))
+//		...
+//	}
+//
+//	func (c *Products) Update() {
+//		...
+//		cache.Invalidate(c.Context.Registry, "product:"+c.Context.GetURLParameter("id"))
+//	}
+package cache
+
+import (
+	"bytes"
+	"context"
+	"github.com/CloudyKit/cloudy"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTTL is the TTL of the responses without max-age when Policy.TTL is 0
+	DefaultTTL = time.Minute
+	// DefaultMaxBodySize is the size of the largest response cached when Policy.MaxBodySize is 0
+	DefaultMaxBodySize = 1 << 20
+)
+
+var (
+	tagsType = reflect.TypeOf((*tagSet)(nil))
+
+	defaultStoreOnce sync.Once
+	defaultStore     Store
+)
+
+type tagSet struct {
+	tags []string
+}
+
+// Tag tags the response of the request, tagged responses are removed by Invalidate
+func Tag(registry cloudy.Registry, tags ...string) {
+	if set, _ := registry.LoadType(tagsType).(*tagSet); set != nil {
+		set.tags = append(set.tags, tags...)
+	}
+}
+
+// Invalidate removes the responses tagged with one of tags from the store of the registry
+func Invalidate(registry cloudy.Registry, tags ...string) error {
+	ctx, _ := registry.LoadType(cloudy.GoContextType).(context.Context)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return storeOf(registry).Invalidate(ctx, tags...)
+}
+
+// storeOf returns the store of the registry or the default memory store
+func storeOf(registry cloudy.Registry) Store {
+	if store := GetStore(registry); store != nil {
+		return store
+	}
+	defaultStoreOnce.Do(func() {
+		defaultStore = NewMemoryStore(0)
+	})
+	return defaultStore
+}
+
+// Policy is a middleware caching the GET and HEAD responses of the route. The response header
+// max-age and s-maxage replace TTL, responses with Set-Cookie or with Cache-Control no-store,
+// no-cache or private are not cached. Requests with Authorization or Cookie, when the header is
+// not one of Headers, are answered from and stored in the cache only with responses explicitly
+// public, RFC 9111 3.5. The request directives no-store, no-cache, max-age,
+// min-fresh, max-stale and only-if-cached are honored. Hits are answered with the X-Cache header
+// HIT, or STALE while the entry is refreshed in the background, the refresh runs only the handlers
+// of the route after the policy, with the headers of the key and without credentials.
+type Policy struct {
+	TTL                  time.Duration // TTL default DefaultTTL
+	StaleWhileRevalidate time.Duration // StaleWhileRevalidate is how long stale responses are served while refreshed
+	Query                []string      // Query parameters in the key, nil means all the query
+	Headers              []string      // Headers of the request in the key, ex: Accept-Language
+	MaxBodySize          int           // MaxBodySize default DefaultMaxBodySize
+	Store                Store         // Store default the store of the registry, see Component
+
+	refreshing sync.Map
+}
+
+func (policy *Policy) store(c *cloudy.Context) Store {
+	if policy.Store != nil {
+		return policy.Store
+	}
+	return storeOf(c.Registry)
+}
+
+func (policy *Policy) maxBodySize() int {
+	if policy.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return policy.MaxBodySize
+}
+
+func (policy *Policy) Handle(c *cloudy.Context) {
+	r := c.Request
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		c.Next()
+		return
+	}
+
+	directives := parseCacheControl(r.Header.Values("Cache-Control"))
+	if _, noStore := directives["no-store"]; noStore {
+		c.Next()
+		return
+	}
+	_, noCache := directives["no-cache"]
+	if len(directives) == 0 && strings.EqualFold(r.Header.Get("Pragma"), "no-cache") {
+		noCache = true
+	}
+
+	ctx := r.Context()
+	store := policy.store(c)
+	key := policy.key(r)
+	credentials := policy.credentials(r)
+	now := time.Now()
+
+	if !noCache {
+		entry, err := lookup(ctx, store, key, r)
+		if err != nil {
+			cloudy.GetSlog(c.Registry).Error("cache get", "key", key, "error", err)
+		}
+		if entry != nil && (!credentials || public(entry.Header)) {
+			if usable, stale := usable(entry, directives, now); usable {
+				if stale {
+					policy.revalidate(c, store, key, entry)
+				}
+				serveEntry(c, entry, stale, now)
+				return
+			}
+		}
+		if _, onlyIfCached := directives["only-if-cached"]; onlyIfCached {
+			c.Response.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+	}
+
+	set := &tagSet{}
+	c.Registry.WithTypeAndValue(tagsType, set)
+
+	response := c.Response
+	response.Header().Set("X-Cache", "MISS")
+	writer := &recordWriter{w: response, before: response.Header().Clone(), limit: policy.maxBodySize()}
+	c.Response = writer
+	defer func() {
+		c.Response = response
+	}()
+	c.Next()
+
+	// the body of HEAD responses is not sent, HEAD requests are answered with the entries of GET
+	if r.Method != http.MethodGet {
+		return
+	}
+	if entry := policy.entry(writer, set.tags, credentials, now); entry != nil {
+		if err := save(ctx, store, key, r, entry); err != nil {
+			cloudy.GetSlog(c.Registry).Error("cache set", "key", key, "error", err)
+		}
+	}
+}
+
+// key returns the key of the request, HEAD requests share the responses of GET
+func (policy *Policy) key(r *http.Request) string {
+	var key strings.Builder
+	key.WriteString("GET ")
+	key.WriteString(strings.ToLower(r.Host))
+	key.WriteString(r.URL.Path)
+
+	query := r.URL.Query()
+	if policy.Query != nil {
+		selected := url.Values{}
+		for _, name := range policy.Query {
+			if values, ok := query[name]; ok {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+	if len(query) > 0 {
+		key.WriteString("?")
+		key.WriteString(query.Encode())
+	}
+	writeHeaders(&key, r, policy.Headers)
+	return key.String()
+}
+
+// credentials reports if the request carries credentials not in the key
+func (policy *Policy) credentials(r *http.Request) bool {
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if r.Header.Get(name) == "" {
+			continue
+		}
+		if !slices.ContainsFunc(policy.Headers, func(header string) bool { return strings.EqualFold(header, name) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// public reports if the Cache-Control of header has the public directive
+func public(header http.Header) bool {
+	_, found := parseCacheControl(header.Values("Cache-Control"))["public"]
+	return found
+}
+
+func writeHeaders(key *strings.Builder, r *http.Request, names []string) {
+	for _, name := range names {
+		key.WriteString("\n")
+		key.WriteString(strings.ToLower(name))
+		key.WriteString(": ")
+		key.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+}
+
+// variantKey returns the key of the variant of the response selected by the vary headers
+func variantKey(key string, r *http.Request, vary []string) string {
+	var variant strings.Builder
+	variant.WriteString(key)
+	variant.WriteString("\nvary")
+	writeHeaders(&variant, r, vary)
+	return variant.String()
+}
+
+// lookup returns the entry of the request, following the vary entry to the variant
+func lookup(ctx context.Context, store Store, key string, r *http.Request) (*Entry, error) {
+	entry, err := store.Get(ctx, key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if entry.Status == 0 && len(entry.Vary) > 0 {
+		return store.Get(ctx, variantKey(key, r, entry.Vary))
+	}
+	return entry, nil
+}
+
+// save stores entry, responses with Vary are stored as variants of a vary entry
+func save(ctx context.Context, store Store, key string, r *http.Request, entry *Entry) error {
+	if len(entry.Vary) == 0 {
+		return store.Set(ctx, key, entry)
+	}
+	err := store.Set(ctx, key, &Entry{
+		Vary:       entry.Vary,
+		Created:    entry.Created,
+		Expires:    entry.Expires,
+		StaleUntil: entry.StaleUntil,
+	})
+	if err != nil {
+		return err
+	}
+	return store.Set(ctx, variantKey(key, r, entry.Vary), entry)
+}
+
+// usable reports if entry can answer a request with directives, stale means the entry must be refreshed
+func usable(entry *Entry, directives map[string]string, now time.Time) (usable, stale bool) {
+	if maxAge, ok := directiveSeconds(directives, "max-age"); ok && now.Sub(entry.Created) > maxAge {
+		return false, false
+	}
+	fresh := entry.Expires
+	if minFresh, ok := directiveSeconds(directives, "min-fresh"); ok {
+		fresh = fresh.Add(-minFresh)
+	}
+	if now.Before(fresh) {
+		return true, false
+	}
+
+	if maxStale, ok := directives["max-stale"]; ok {
+		if maxStale == "" {
+			return true, true
+		}
+		if seconds, ok := directiveSeconds(directives, "max-stale"); ok && now.Sub(entry.Expires) <= seconds {
+			return true, true
+		}
+	}
+	return now.Before(entry.StaleUntil), true
+}
+
+// serveEntry answers the request with entry, the conditional headers are evaluated with the cached validators
+func serveEntry(c *cloudy.Context, entry *Entry, stale bool, now time.Time) {
+	header := c.Response.Header()
+	for name, values := range entry.Header {
+		if name == "Vary" {
+			// keeps the vary of the middlewares running before the cache, ex: compression
+			header[name] = append(header[name], values...)
+			continue
+		}
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(entry.Created)/time.Second)))
+	if stale {
+		header.Set("X-Cache", "STALE")
+	} else {
+		header.Set("X-Cache", "HIT")
+	}
+
+	if etag := header.Get("ETag"); etag != "" && c.SetETag(strings.TrimPrefix(etag, "W/"), strings.HasPrefix(etag, "W/")) {
+		return
+	}
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && c.SetLastModified(modified) {
+		return
+	}
+
+	c.Response.WriteHeader(entry.Status)
+	if c.Request.Method != http.MethodHead {
+		_, _ = c.Response.Write(entry.Body)
+	}
+}
+
+// revalidate refreshes the entry of key in the background running the handlers of the route
+// after the policy with a new GET request, the request keeps only the headers of the key, the
+// middlewares running before the policy are not run again. Requests with credentials don't
+// refresh the entry, one refresh runs per key.
+func (policy *Policy) revalidate(c *cloudy.Context, store Store, key string, entry *Entry) {
+	if policy.credentials(c.Request) {
+		return
+	}
+	if _, running := policy.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	request := c.Request.Clone(ctx)
+	request.Method = http.MethodGet
+	request.Body = http.NoBody
+	request.ContentLength = 0
+	request.Header = make(http.Header)
+	for _, names := range [][]string{policy.Headers, entry.Vary} {
+		for _, name := range names {
+			if values := c.Request.Header.Values(name); len(values) > 0 {
+				request.Header[http.CanonicalHeaderKey(name)] = slices.Clone(values)
+			}
+		}
+	}
+
+	replay := c.Replay()
+	logger := cloudy.GetSlog(c.Registry)
+	go func() {
+		defer policy.refreshing.Delete(key)
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error("cache revalidate", "key", key, "error", err)
+			}
+		}()
+
+		set := &tagSet{}
+		writer := &recordWriter{w: &discardWriter{header: make(http.Header)}, before: make(http.Header), limit: policy.maxBodySize()}
+		replay(writer, request, cloudy.HandlerFunc(func(c *cloudy.Context) {
+			c.Registry.WithTypeAndValue(tagsType, set)
+			c.Next()
+		}))
+		if entry := policy.entry(writer, set.tags, false, time.Now()); entry != nil {
+			if err := save(ctx, store, key, request, entry); err != nil {
+				logger.Error("cache set", "key", key, "error", err)
+			}
+		}
+	}()
+}
+
+// entry returns the entry of the recorded response, nil when the response can't be cached,
+// responses to requests with credentials are cached only when public
+func (policy *Policy) entry(writer *recordWriter, tags []string, credentials bool, now time.Time) *Entry {
+	if writer.status == 0 || writer.skip || writer.cookie || !cacheableStatus(writer.status) {
+		return nil
+	}
+
+	header := writer.header
+	directives := parseCacheControl(header.Values("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, found := directives[directive]; found {
+			return nil
+		}
+	}
+	if _, found := directives["public"]; credentials && !found {
+		return nil
+	}
+
+	var vary []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "*" {
+				return nil
+			} else if name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	ttl := policy.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if maxAge, ok := directiveSeconds(directives, "s-maxage"); ok {
+		ttl = maxAge
+	} else if maxAge, ok := directiveSeconds(directives, "max-age"); ok {
+		ttl = maxAge
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	header.Del("X-Cache")
+	header.Del("Age")
+	expires := now.Add(ttl)
+	return &Entry{
+		Status:     writer.status,
+		Header:     header,
+		Body:       bytes.Clone(writer.body.Bytes()),
+		Tags:       tags,
+		Vary:       vary,
+		Created:    now,
+		Expires:    expires,
+		StaleUntil: expires.Add(policy.StaleWhileRevalidate),
+	}
+}
+
+// cacheableStatus reports if responses with status are cacheable by default, RFC 9110 15.1
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// parseCacheControl returns the directives of the Cache-Control values with lower case names
+func parseCacheControl(values []string) map[string]string {
+	directives := map[string]string{}
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			name, argument, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				directives[name] = strings.Trim(strings.TrimSpace(argument), `"`)
+			}
+		}
+	}
+	return directives
+}
+
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(directives[name])
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// recordWriter sends the response and keeps a copy, the header kept is the one set by the
+// next handlers, headers set before the cache by other middlewares are not cached but a
+// Set-Cookie from any of them marks the response as not cacheable
+type recordWriter struct {
+	w      http.ResponseWriter
+	before http.Header
+	header http.Header
+	status int
+	body   bytes.Buffer
+	limit  int
+	skip   bool
+	cookie bool
+}
+
+func (rw *recordWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *recordWriter) WriteHeader(status int) {
+	// informational responses are sent as they come
+	if status < 200 {
+		rw.w.WriteHeader(status)
+		return
+	}
+	if rw.status != 0 {
+		return
+	}
+	rw.status = status
+	rw.cookie = len(rw.w.Header().Values("Set-Cookie")) > 0
+	rw.header = make(http.Header)
+	for name, values := range rw.w.Header() {
+		if before, found := rw.before[name]; !found || !slices.Equal(before, values) {
+			rw.header[name] = append([]string(nil), values...)
+		}
+	}
+	rw.w.WriteHeader(status)
+}
+
+func (rw *recordWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if !rw.skip {
+		if rw.body.Len()+len(b) > rw.limit {
+			rw.skip = true
+			rw.body.Reset()
+		} else {
+			rw.body.Write(b)
+		}
+	}
+	return rw.w.Write(b)
+}
+
+// Flush sends the response, flushed responses are streams and are not cached
+func (rw *recordWriter) Flush() {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.skip = true
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, see http.ResponseController
+func (rw *recordWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// discardWriter receives the responses of the background refreshes
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+// Component provides the Store to the policies of the kernel, invalidated with Invalidate, and
+// applies Policy to all the GET and HEAD requests of the kernel
+type Component struct {
+	Store  Store   // Store default NewMemoryStore(0)
+	Policy *Policy // Policy applied to all the requests of the kernel, optional
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	if component.Store == nil {
+		component.Store = NewMemoryStore(0)
+	}
+	kernel.Registry.WithTypeAndValue(StoreType, component.Store)
+	if component.Policy != nil {
+		kernel.Root().AddMiddleware(component.Policy)
+	}
+}
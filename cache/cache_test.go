@@ -0,0 +1,314 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cache
+
+import (
+	"context"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/session"
+	"github.com/CloudyKit/cloudy/session/store/file"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type products struct {
+	Context *cloudy.Context
+}
+
+var (
+	productViews   atomic.Int32
+	productVersion atomic.Int32
+)
+
+func (p *products) Mx(mx *cloudy.Mapper) {
+	mx.Name = "Products"
+	mx.BindAction("GET", "/products/:id", "Show", &Policy{TTL: time.Hour, Query: []string{"fields"}})
+	mx.BindAction("PUT", "/products/:id", "Update")
+}
+
+func (p *products) Show() {
+	productViews.Add(1)
+	id := p.Context.GetURLParameter("id")
+	Tag(p.Context.Registry, "product:"+id)
+	p.Context.Response.Header().Set("ETag", `"`+strconv.Itoa(int(productVersion.Load()))+`"`)
+	p.Context.Printf("product %s v%d", id, productVersion.Load())
+}
+
+func (p *products) Update() {
+	productVersion.Add(1)
+	if err := Invalidate(p.Context.Registry, "product:"+p.Context.GetURLParameter("id")); err != nil {
+		panic(err)
+	}
+}
+
+func serve(kernel *cloudy.Kernel, method, path string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	for i := 0; i < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestPolicy(t *testing.T) {
+	productViews.Store(0)
+	productVersion.Store(0)
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{})
+	kernel.AddControllers(&products{})
+
+	if recorder := serve(kernel, "GET", "/products/1"); recorder.Header().Get("X-Cache") != "MISS" || recorder.Body.String() != "product 1 v0" {
+		t.Fatalf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+	recorder := serve(kernel, "GET", "/products/1?utm=mail")
+	if recorder.Header().Get("X-Cache") != "HIT" || recorder.Body.String() != "product 1 v0" || recorder.Header().Get("Age") == "" {
+		t.Fatalf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+	if recorder := serve(kernel, "HEAD", "/products/1"); recorder.Header().Get("X-Cache") != "HIT" || recorder.Body.Len() != 0 {
+		t.Errorf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+	if recorder := serve(kernel, "GET", "/products/1", "If-None-Match", `"0"`); recorder.Code != 304 {
+		t.Errorf("the cached etag was not evaluated, got %d", recorder.Code)
+	}
+	if recorder := serve(kernel, "GET", "/products/1?fields=name"); recorder.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("selected query parameters should be in the key")
+	}
+	if productViews.Load() != 2 {
+		t.Errorf("expected 2 views, got %d", productViews.Load())
+	}
+
+	// the request directives
+	if recorder := serve(kernel, "GET", "/products/1", "Cache-Control", "no-cache"); recorder.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("no-cache should skip the cache")
+	}
+	if recorder := serve(kernel, "GET", "/products/1", "Cache-Control", "no-store"); recorder.Header().Get("X-Cache") != "" {
+		t.Errorf("no-store should bypass the cache, got %q", recorder.Header().Get("X-Cache"))
+	}
+	if recorder := serve(kernel, "GET", "/products/2", "Cache-Control", "only-if-cached"); recorder.Code != 504 {
+		t.Errorf("expected 504, got %d", recorder.Code)
+	}
+
+	// updates invalidate the tagged responses
+	serve(kernel, "PUT", "/products/1")
+	if recorder := serve(kernel, "GET", "/products/1"); recorder.Header().Get("X-Cache") != "MISS" || recorder.Body.String() != "product 1 v1" {
+		t.Errorf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+	if recorder := serve(kernel, "GET", "/products/1?fields=name"); recorder.Body.String() != "product 1 v1" {
+		t.Errorf("tagged variant was not invalidated %q", recorder.Body.String())
+	}
+}
+
+func TestPolicy_Vary(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddHandlerFunc("GET", "/greeting", func(c *cloudy.Context) {
+		c.Response.Header().Set("Vary", "Accept-Language")
+		if c.Request.Header.Get("Accept-Language") == "pt" {
+			c.WriteString("olá")
+			return
+		}
+		c.WriteString("hello")
+	}, &Policy{Store: NewMemoryStore(0)})
+	kernel.AddHandlerFunc("GET", "/private", func(c *cloudy.Context) {
+		c.Response.Header().Set("Cache-Control", "private")
+		c.WriteString("private")
+	}, &Policy{Store: NewMemoryStore(0)})
+
+	for i := 0; i < 2; i++ {
+		english := serve(kernel, "GET", "/greeting", "Accept-Language", "en")
+		portuguese := serve(kernel, "GET", "/greeting", "Accept-Language", "pt")
+		if english.Body.String() != "hello" || portuguese.Body.String() != "olá" {
+			t.Fatalf("request %d: unexpected variants %q %q", i, english.Body.String(), portuguese.Body.String())
+		}
+		if i == 1 && (english.Header().Get("X-Cache") != "HIT" || portuguese.Header().Get("X-Cache") != "HIT") {
+			t.Errorf("variants were not cached")
+		}
+	}
+	serve(kernel, "GET", "/private")
+	if recorder := serve(kernel, "GET", "/private"); recorder.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("private responses should not be cached")
+	}
+}
+
+func TestPolicy_Credentials(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&session.Component{
+		Manager: session.New(time.Hour, time.Hour, file.New(t.TempDir()), session.GobSessionEncoder{}, session.RandGenerator{}),
+	})
+	kernel.AddHandlerFunc("GET", "/hello", func(c *cloudy.Context) {
+		c.Printf("hello session %s", session.GetSessionManager(c.Registry).ID())
+	}, &Policy{Store: NewMemoryStore(0)})
+
+	first := serve(kernel, "GET", "/hello")
+	cookie := first.Result().Cookies()[0]
+	second := serve(kernel, "GET", "/hello")
+	if second.Header().Get("X-Cache") != "MISS" || second.Body.String() == first.Body.String() {
+		t.Fatalf("the session response leaked to another client %q %v", second.Body.String(), second.Header())
+	}
+	if recorder := serve(kernel, "GET", "/hello", "Cookie", cookie.String()); recorder.Header().Get("X-Cache") != "MISS" || recorder.Body.String() != first.Body.String() {
+		t.Errorf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+
+	kernel = cloudy.NewKernel()
+	kernel.AddHandlerFunc("GET", "/account", func(c *cloudy.Context) {
+		c.Printf("account %s", c.Request.Header.Get("Authorization"))
+	}, &Policy{Store: NewMemoryStore(0)})
+	kernel.AddHandlerFunc("GET", "/logo", func(c *cloudy.Context) {
+		c.Response.Header().Set("Cache-Control", "public")
+		c.WriteString("logo")
+	}, &Policy{Store: NewMemoryStore(0)})
+	kernel.AddHandlerFunc("GET", "/preferences", func(c *cloudy.Context) {
+		c.Printf("preferences %s", c.Request.Header.Get("Cookie"))
+	}, &Policy{Headers: []string{"Cookie"}, Store: NewMemoryStore(0)})
+
+	serve(kernel, "GET", "/account", "Authorization", "Bearer a")
+	if recorder := serve(kernel, "GET", "/account", "Authorization", "Bearer b"); recorder.Body.String() != "account Bearer b" {
+		t.Errorf("the authorized response leaked to another client %q", recorder.Body.String())
+	}
+	serve(kernel, "GET", "/account")
+	if recorder := serve(kernel, "GET", "/account", "Authorization", "Bearer b"); recorder.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("authorized requests should not be answered with responses not public")
+	}
+	serve(kernel, "GET", "/logo", "Authorization", "Bearer a")
+	if recorder := serve(kernel, "GET", "/logo", "Cookie", "theme=dark"); recorder.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("public responses should be shared")
+	}
+	serve(kernel, "GET", "/preferences", "Cookie", "theme=dark")
+	if recorder := serve(kernel, "GET", "/preferences", "Cookie", "theme=light"); recorder.Body.String() != "preferences theme=light" {
+		t.Errorf("the cookie should be in the key, got %q", recorder.Body.String())
+	}
+	if recorder := serve(kernel, "GET", "/preferences", "Cookie", "theme=dark"); recorder.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("responses keyed by the cookie should be cached")
+	}
+}
+
+func TestPolicy_StaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	kernel := cloudy.NewKernel()
+	kernel.AddHandlerFunc("GET", "/feed", func(c *cloudy.Context) {
+		c.Printf("v%d", version.Load())
+	}, &Policy{TTL: 20 * time.Millisecond, StaleWhileRevalidate: time.Hour, Store: NewMemoryStore(0)})
+
+	serve(kernel, "GET", "/feed")
+	version.Store(1)
+	time.Sleep(30 * time.Millisecond)
+
+	recorder := serve(kernel, "GET", "/feed")
+	if recorder.Header().Get("X-Cache") != "STALE" || recorder.Body.String() != "v0" {
+		t.Fatalf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+	if recorder := serve(kernel, "GET", "/feed", "Cache-Control", "max-age=0"); recorder.Header().Get("X-Cache") == "STALE" {
+		t.Errorf("max-age=0 should not accept stale responses")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if recorder = serve(kernel, "GET", "/feed"); recorder.Header().Get("X-Cache") == "HIT" && recorder.Body.String() == "v1" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("the entry was not refreshed, last response %q %v", recorder.Body.String(), recorder.Header())
+}
+
+func TestPolicy_RevalidateRequest(t *testing.T) {
+	var middlewares atomic.Int32
+	requests := make(chan http.Header, 4)
+	kernel := cloudy.NewKernel()
+	kernel.AddMiddlewareFunc(func(c *cloudy.Context) {
+		middlewares.Add(1)
+		c.Next()
+	})
+	kernel.AddHandlerFunc("GET", "/feed", func(c *cloudy.Context) {
+		requests <- c.Request.Header.Clone()
+		c.Response.Header().Set("Cache-Control", "public")
+		c.Response.Header().Set("Vary", "Accept-Language")
+		c.WriteString("feed")
+	}, &Policy{TTL: 20 * time.Millisecond, StaleWhileRevalidate: time.Hour, Store: NewMemoryStore(0)})
+
+	serve(kernel, "GET", "/feed", "Accept-Language", "pt")
+	<-requests
+	time.Sleep(30 * time.Millisecond)
+
+	if recorder := serve(kernel, "GET", "/feed", "Accept-Language", "pt", "X-Tenant", "a"); recorder.Header().Get("X-Cache") != "STALE" {
+		t.Fatalf("unexpected response %v", recorder.Header())
+	}
+	select {
+	case header := <-requests:
+		if header.Get("Accept-Language") != "pt" || header.Get("X-Tenant") != "" {
+			t.Errorf("the refresh should keep only the headers of the key, got %v", header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the entry was not refreshed")
+	}
+	if middlewares.Load() != 2 {
+		t.Errorf("the refresh should not run the middlewares before the policy, got %d runs", middlewares.Load())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if recorder := serve(kernel, "GET", "/feed", "Accept-Language", "pt", "Authorization", "Bearer a"); recorder.Header().Get("X-Cache") != "STALE" {
+		t.Fatalf("unexpected response %v", recorder.Header())
+	}
+	select {
+	case header := <-requests:
+		t.Errorf("requests with credentials should not refresh the entry, got %v", header)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(30)
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	entry := func(body string, tags ...string) *Entry {
+		return &Entry{Status: 200, Body: []byte(body), Tags: tags, StaleUntil: now.Add(time.Minute)}
+	}
+	store.Set(ctx, "a", entry("0123456789", "x"))
+	store.Set(ctx, "b", entry("0123456789", "y"))
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", entry("0123456789", "x"))
+
+	if got, _ := store.Get(ctx, "b"); got != nil {
+		t.Error("the least recently used entry was not evicted")
+	}
+	if got, _ := store.Get(ctx, "a"); got == nil {
+		t.Error("the recently used entry was evicted")
+	}
+
+	store.Invalidate(ctx, "x")
+	if got, _ := store.Get(ctx, "c"); got != nil || store.size != 0 || len(store.tags) != 0 {
+		t.Errorf("tagged entries were not removed, size %d tags %v", store.size, store.tags)
+	}
+
+	store.Set(ctx, "d", entry("d"))
+	now = now.Add(2 * time.Minute)
+	if got, _ := store.Get(ctx, "d"); got != nil {
+		t.Error("expired entry was returned")
+	}
+}
@@ -0,0 +1,189 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"github.com/CloudyKit/cloudy"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var StoreType = reflect.TypeOf((*Store)(nil)).Elem()
+
+// GetStore returns the store of the registry, nil when there is none
+func GetStore(registry cloudy.Registry) Store {
+	store, _ := registry.LoadType(StoreType).(Store)
+	return store
+}
+
+// Entry is a cached response, an entry with Vary and no Status lists the request headers
+// selecting the variants of the response
+type Entry struct {
+	Status     int
+	Header     http.Header
+	Body       []byte
+	Tags       []string
+	Vary       []string
+	Created    time.Time
+	Expires    time.Time // Expires is when the entry becomes stale
+	StaleUntil time.Time // StaleUntil is when the entry can't be served anymore, the store may drop it
+}
+
+// Store keeps the cached responses, external backends implement Store keeping an index of the
+// keys by tag for Invalidate
+type Store interface {
+	// Get returns the entry of key, nil when missing
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, entry *Entry) error
+	// Invalidate removes the entries tagged with one of tags
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+// DefaultMemorySize is the size of NewMemoryStore(0)
+const DefaultMemorySize = 64 << 20
+
+// MemoryStore keeps the entries in process, the least recently used entries are removed when
+// the size of the entries passes the limit
+type MemoryStore struct {
+	mx      sync.Mutex
+	limit   int64
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+	tags    map[string]map[string]struct{}
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// NewMemoryStore creates a store keeping up to limit bytes of responses, 0 means DefaultMemorySize
+func NewMemoryStore(limit int64) *MemoryStore {
+	if limit <= 0 {
+		limit = DefaultMemorySize
+	}
+	return &MemoryStore{
+		limit:   limit,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+		now:     time.Now,
+	}
+}
+
+func (store *MemoryStore) Get(_ context.Context, key string) (*Entry, error) {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	element := store.entries[key]
+	if element == nil {
+		return nil, nil
+	}
+	entry := element.Value.(*memoryEntry).entry
+	if store.now().After(entry.StaleUntil) {
+		store.remove(element)
+		return nil, nil
+	}
+	store.lru.MoveToFront(element)
+	return entry, nil
+}
+
+func (store *MemoryStore) Set(_ context.Context, key string, entry *Entry) error {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	if element := store.entries[key]; element != nil {
+		store.remove(element)
+	}
+
+	size := entrySize(key, entry)
+	if size > store.limit {
+		return nil
+	}
+	store.entries[key] = store.lru.PushFront(&memoryEntry{key: key, entry: entry, size: size})
+	store.size += size
+	for _, tag := range entry.Tags {
+		keys := store.tags[tag]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			store.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for store.size > store.limit {
+		store.remove(store.lru.Back())
+	}
+	return nil
+}
+
+func (store *MemoryStore) Invalidate(_ context.Context, tags ...string) error {
+	store.mx.Lock()
+	defer store.mx.Unlock()
+
+	for _, tag := range tags {
+		for key := range store.tags[tag] {
+			if element := store.entries[key]; element != nil {
+				store.remove(element)
+			}
+		}
+		delete(store.tags, tag)
+	}
+	return nil
+}
+
+func (store *MemoryStore) remove(element *list.Element) {
+	memoryEntry := store.lru.Remove(element).(*memoryEntry)
+	delete(store.entries, memoryEntry.key)
+	store.size -= memoryEntry.size
+	for _, tag := range memoryEntry.entry.Tags {
+		if keys := store.tags[tag]; keys != nil {
+			delete(keys, memoryEntry.key)
+			if len(keys) == 0 {
+				delete(store.tags, tag)
+			}
+		}
+	}
+}
+
+// entrySize approximates the memory used by the entry
+func entrySize(key string, entry *Entry) int64 {
+	size := int64(len(key) + len(entry.Body))
+	for name, values := range entry.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	for _, tag := range entry.Tags {
+		size += int64(len(tag))
+	}
+	return size
+}
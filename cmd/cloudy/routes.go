@@ -0,0 +1,182 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+var loaderTemplate = template.Must(template.New("loader").Parse(`// Code generated by cloudy routes. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+{{- if eq .Kernel "DefaultKernel"}}
+	"github.com/CloudyKit/cloudy"
+	_ {{printf "%q" .ImportPath}}
+{{- else}}
+	app {{printf "%q" .ImportPath}}
+{{- end}}
+)
+
+func main() {
+	if err := json.NewEncoder(os.Stdout).Encode({{if eq .Kernel "DefaultKernel"}}cloudy{{else}}app{{end}}.{{.Kernel}}.Routes()); err != nil {
+		panic(err)
+	}
+}
+`))
+
+func routesCommand(args []string) error {
+	flags := flag.NewFlagSet("routes", flag.ExitOnError)
+	kernelVar := flags.String("kernel", "DefaultKernel", "name of the package level variable holding the *cloudy.Kernel, DefaultKernel is cloudy.DefaultKernel")
+	asJSON := flags.Bool("json", false, "prints the route table as json")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: cloudy routes [-kernel DefaultKernel] [-json] <package>")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	pkg := "."
+	if flags.NArg() > 0 {
+		pkg = flags.Arg(0)
+	}
+
+	routes, moduleDir, err := loadRoutes(pkg, *kernelVar)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(routes)
+	}
+	return printRoutes(os.Stdout, routes, moduleDir)
+}
+
+// loadRoutes runs pkg and reads the route table it writes as json, a package main is run
+// with RoutesEnv set and must reach Kernel.RoutesHook, any other package is imported by a
+// small program generated outside the module
+func loadRoutes(pkg, kernelVar string) ([]cloudy.Route, string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.Name}}\t{{.ImportPath}}\t{{with .Module}}{{.Dir}}{{end}}", pkg).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, "", fmt.Errorf("go list %s: %s", pkg, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, "", err
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(string(out)), "\t", 3)
+	if len(fields) != 3 || fields[2] == "" {
+		return nil, "", fmt.Errorf("package %s is not part of a module", pkg)
+	}
+	name, importPath, moduleDir := fields[0], fields[1], fields[2]
+
+	var cmd *exec.Cmd
+	if name == "main" {
+		cmd = exec.Command("go", "run", importPath)
+		cmd.Env = append(os.Environ(), cloudy.RoutesEnv+"=1")
+	} else {
+		// the loader is run from the module directory to use the module dependencies
+		loaderDir, err := os.MkdirTemp("", "cloudy-routes-")
+		if err != nil {
+			return nil, "", err
+		}
+		defer os.RemoveAll(loaderDir)
+
+		var source bytes.Buffer
+		err = loaderTemplate.Execute(&source, struct{ ImportPath, Kernel string }{importPath, kernelVar})
+		if err != nil {
+			return nil, "", err
+		}
+		loader := filepath.Join(loaderDir, "main.go")
+		if err = os.WriteFile(loader, source.Bytes(), 0666); err != nil {
+			return nil, "", err
+		}
+		cmd = exec.Command("go", "run", loader)
+	}
+
+	var stdout bytes.Buffer
+	cmd.Dir = moduleDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return nil, "", fmt.Errorf("loading %s: %w", importPath, err)
+	}
+
+	var routes []cloudy.Route
+	if err = json.Unmarshal(stdout.Bytes(), &routes); err != nil {
+		if name == "main" {
+			return nil, "", fmt.Errorf("package main %s did not print the route table, call Kernel.RunServer or Kernel.RoutesHook in main once the routes are added: %w", importPath, err)
+		}
+		return nil, "", fmt.Errorf("decoding the route table: %w", err)
+	}
+	return routes, moduleDir, nil
+}
+
+func printRoutes(w io.Writer, routes []cloudy.Route, baseDir string) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "METHOD\tPATH\tNAME\tHANDLER\tMIDDLEWARES\tSOURCE")
+	for _, route := range routes {
+		source := route.Source
+		if rel, err := filepath.Rel(baseDir, source); err == nil && !strings.HasPrefix(rel, "..") {
+			source = rel
+		}
+		path := route.Path
+		if route.Host != "" {
+			path = route.Host + path
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			route.Method,
+			path,
+			orDash(route.Name),
+			route.Handler,
+			orDash(strings.Join(route.Middlewares, ", ")),
+			orDash(source),
+		)
+	}
+	return table.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,336 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package compress compresses the responses with gzip or deflate as negotiated by Accept-Encoding.
+//
+//	kernel.AddComponents(&compress.Component{})
+//
+// The Component is also a middleware, ex: a controller compressing with its own settings
+//
+//	mx.AddMiddleware(&compress.Component{Level: gzip.BestCompression})
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/CloudyKit/cloudy"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultContentTypes are the content types compressed when Component.ContentTypes is empty
+var DefaultContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// DefaultMinSize is the minimum size of the compressed responses when Component.MinSize is 0
+const DefaultMinSize = 1024
+
+// Component compresses the responses of the kernel, responses are compressed when the status has a
+// body, the content type is allowed and the body has at least MinSize bytes. Responses with a
+// Content-Encoding and responses flushed before MinSize bytes are written are sent as they are.
+// The response writer of the next handlers implements http.Hijacker, hijacking reaches the writer
+// of the request and the response is no longer written by the component.
+type Component struct {
+	Level        int      // Level of gzip and deflate, default gzip.DefaultCompression
+	MinSize      int      // MinSize default DefaultMinSize
+	ContentTypes []string // ContentTypes allowed, ex: text/* or application/json, default DefaultContentTypes
+
+	once  sync.Once
+	pools map[string]*sync.Pool
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	kernel.Root().AddMiddleware(component)
+}
+
+// encoder is implemented by gzip.Writer and zlib.Writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (component *Component) init() {
+	level := component.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	component.pools = map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+		"deflate": {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+	}
+}
+
+func (component *Component) minSize() int {
+	if component.MinSize > 0 {
+		return component.MinSize
+	}
+	return DefaultMinSize
+}
+
+// allowContentType reports if contentType is in the allow list
+func (component *Component) allowContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	contentTypes := component.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultContentTypes
+	}
+	for _, allowed := range contentTypes {
+		if prefix, wildcard := strings.CutSuffix(allowed, "*"); wildcard {
+			if strings.HasPrefix(mediaType, prefix) {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (component *Component) Handle(c *cloudy.Context) {
+	component.once.Do(component.init)
+
+	addVary(c.Response.Header(), "Accept-Encoding")
+	encoding := negotiate(c.Request.Header.Values("Accept-Encoding"))
+	if encoding == "" || c.Request.Method == http.MethodHead {
+		c.Next()
+		return
+	}
+
+	response := c.Response
+	writer := &compressWriter{component: component, encoding: encoding, w: response}
+	c.Response = writer
+	defer func() {
+		c.Response = response
+		writer.finish()
+	}()
+	c.Next()
+}
+
+// negotiate returns gzip or deflate, the coding with the highest quality, gzip is preferred on ties,
+// * applies to the codings not listed
+func negotiate(values []string) string {
+	qualities := map[string]float64{}
+	for _, value := range values {
+		for _, accepted := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "x-gzip" {
+				coding = "gzip"
+			}
+			quality := 1.0
+			if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					quality = parsed
+				}
+			}
+			qualities[coding] = quality
+		}
+	}
+
+	best, bestQuality := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		quality, listed := qualities[coding]
+		if !listed {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = coding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the body until MinSize bytes are written, then decides if the response
+// is compressed, the header is sent once decided
+type compressWriter struct {
+	component *Component
+	encoding  string
+	w         http.ResponseWriter
+	status    int
+	buffer    bytes.Buffer
+	decided   bool
+	encoder   encoder
+}
+
+func (cw *compressWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	// informational responses are sent as they come
+	if status < 200 {
+		cw.w.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	if !bodyAllowed(status) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buffer.Write(b)
+		if cw.buffer.Len() < cw.component.minSize() {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.w.Write(b)
+}
+
+// Flush sends the response as it is when the response is not compressed yet, streamed responses
+// are not delayed by the compression
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.decide(false)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if flusher, ok := cw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, see http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.w
+}
+
+// Hijack hijacks the connection of the wrapped writer, the response is not written by the
+// compression after a successful hijack, ex: websocket upgrades
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(cw.w).Hijack()
+	if err == nil {
+		cw.decided = true
+		cw.buffer.Reset()
+	}
+	return conn, rw, err
+}
+
+// decide writes the header, compressing when allowed and the response qualifies, and the buffered body,
+// responses without body are sent as they are
+func (cw *compressWriter) decide(allowed bool) error {
+	cw.decided = true
+	header := cw.w.Header()
+	if allowed && cw.buffer.Len() > 0 && bodyAllowed(cw.status) && cw.status != http.StatusPartialContent && header.Get("Content-Encoding") == "" {
+		contentType := header.Get("Content-Type")
+		if contentType == "" {
+			// sniffs the plain body, the server can't sniff the compressed one
+			contentType = http.DetectContentType(cw.buffer.Bytes())
+			header.Set("Content-Type", contentType)
+		}
+		if cw.component.allowContentType(contentType) && cw.buffer.Len() >= cw.component.minSize() {
+			header.Set("Content-Encoding", cw.encoding)
+			header.Del("Content-Length")
+			header.Del("Accept-Ranges")
+			// the compressed representation differs byte by byte from the plain one
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+			cw.encoder = cw.component.pools[cw.encoding].Get().(encoder)
+			cw.encoder.Reset(cw.w)
+		}
+	}
+
+	cw.w.WriteHeader(cw.status)
+	if cw.buffer.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buffer.Bytes())
+	} else {
+		_, err = cw.w.Write(cw.buffer.Bytes())
+	}
+	cw.buffer.Reset()
+	return err
+}
+
+// finish sends the responses smaller than MinSize and completes the compressed stream
+func (cw *compressWriter) finish() {
+	if !cw.decided && cw.status != 0 {
+		cw.decide(true)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+		cw.encoder.Reset(io.Discard)
+		cw.component.pools[cw.encoding].Put(cw.encoder)
+		cw.encoder = nil
+	}
+}
+
+// bodyAllowed reports if a response with status has a body
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// addVary adds name to the Vary header when not present
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, present := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(present), name) || strings.TrimSpace(present) == "*" {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
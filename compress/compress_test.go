@@ -0,0 +1,190 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/CloudyKit/cloudy"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var large = strings.Repeat("cloudy ", 400)
+
+func newKernel() *cloudy.Kernel {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{})
+	kernel.AddHandlerFunc("GET", "/json", func(c *cloudy.Context) {
+		c.SendJSONStatusCode(201, map[string]string{"text": large})
+	})
+	kernel.AddHandlerFunc("GET", "/text", func(c *cloudy.Context) {
+		c.Response.Header().Set("Content-Length", strconv.Itoa(len(large)))
+		c.Response.Header().Set("ETag", `"v1"`)
+		c.SendTextWithStatus(200, large)
+	})
+	kernel.AddHandlerFunc("GET", "/small", func(c *cloudy.Context) {
+		c.SendTextWithStatus(200, "small")
+	})
+	kernel.AddHandlerFunc("GET", "/png", func(c *cloudy.Context) {
+		c.Response.Header().Set("Content-Type", "image/png")
+		c.WriteString(large)
+	})
+	kernel.AddHandlerFunc("GET", "/encoded", func(c *cloudy.Context) {
+		c.Response.Header().Set("Content-Encoding", "br")
+		c.WriteString(large)
+	})
+	kernel.AddHandlerFunc("GET", "/stream", func(c *cloudy.Context) {
+		c.Response.Header().Set("Content-Type", "text/event-stream")
+		c.WriteString("data: 1\n\n")
+		c.Response.(interface{ Flush() }).Flush()
+		c.WriteString(large)
+	})
+	kernel.AddHandlerFunc("GET", "/empty", func(c *cloudy.Context) {
+		c.Response.WriteHeader(204)
+	})
+	kernel.AddHandlerFunc("GET", "/created", func(c *cloudy.Context) {
+		c.Response.Header().Set("Location", "/json")
+		c.Response.WriteHeader(201)
+	})
+	kernel.AddHandlerFunc("GET", "/hijack", func(c *cloudy.Context) {
+		conn, rw, err := c.Response.(http.Hijacker).Hijack()
+		if err != nil {
+			c.Response.WriteHeader(500)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})
+	return kernel
+}
+
+func serve(kernel *cloudy.Kernel, path, acceptEncoding string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", path, nil)
+	if acceptEncoding != "" {
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	t.Helper()
+	var reader io.Reader
+	var err error
+	switch encoding := recorder.Header().Get("Content-Encoding"); encoding {
+	case "gzip":
+		reader, err = gzip.NewReader(recorder.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(recorder.Body)
+	default:
+		t.Fatalf("unexpected encoding %q", encoding)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestComponent(t *testing.T) {
+	kernel := newKernel()
+
+	// the pooled writers are reused by the second request
+	for i := 0; i < 2; i++ {
+		recorder := serve(kernel, "/json", "gzip, deflate")
+		if recorder.Code != 201 || recorder.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+		}
+		if body := decode(t, recorder); !strings.Contains(body, large) {
+			t.Fatalf("unexpected body %q", body)
+		}
+		if vary := recorder.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("unexpected vary %q", vary)
+		}
+	}
+
+	recorder := serve(kernel, "/text", "gzip;q=0.5, deflate")
+	if decode(t, recorder) != large || recorder.Header().Get("Content-Length") != "" || recorder.Header().Get("ETag") != `W/"v1"` {
+		t.Errorf("unexpected response %v", recorder.Header())
+	}
+	if recorder.Header().Get("Content-Encoding") != "deflate" {
+		t.Errorf("expected deflate, got %q", recorder.Header().Get("Content-Encoding"))
+	}
+
+	for path, acceptEncoding := range map[string]string{
+		"/text":    "",
+		"/small":   "gzip",
+		"/png":     "gzip",
+		"/encoded": "gzip",
+		"/stream":  "gzip",
+	} {
+		recorder := serve(kernel, path, acceptEncoding)
+		if encoding := recorder.Header().Get("Content-Encoding"); encoding == "gzip" || encoding == "deflate" {
+			t.Errorf("%s: unexpected compression", path)
+		}
+		if recorder.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: missing vary", path)
+		}
+	}
+	if recorder := serve(kernel, "/text", "gzip;q=0, identity"); recorder.Header().Get("Content-Encoding") != "" || recorder.Body.String() != large {
+		t.Errorf("gzip;q=0 was compressed")
+	}
+	if recorder := serve(kernel, "/text", "gzip;q=0, *"); recorder.Header().Get("Content-Encoding") != "deflate" {
+		t.Errorf("expected * to select deflate, got %q", recorder.Header().Get("Content-Encoding"))
+	}
+	if recorder := serve(kernel, "/stream", "gzip"); !strings.HasPrefix(recorder.Body.String(), "data: 1\n\n") || !recorder.Flushed {
+		t.Errorf("stream was not flushed as plain text")
+	}
+	if recorder := serve(kernel, "/empty", "gzip"); recorder.Code != 204 || recorder.Header().Get("Content-Encoding") != "" {
+		t.Errorf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+	if recorder := serve(kernel, "/created", "gzip"); recorder.Code != 201 || recorder.Header().Get("Content-Type") != "" {
+		t.Errorf("a response without body should not be sniffed %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestComponent_Hijack(t *testing.T) {
+	server := httptest.NewServer(newKernel())
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/hijack", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	response, err := http.DefaultTransport.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if body, _ := io.ReadAll(response.Body); response.StatusCode != 200 || string(body) != "hijacked" {
+		t.Errorf("unexpected response %d %q", response.StatusCode, body)
+	}
+}
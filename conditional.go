@@ -0,0 +1,286 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagBufferSize is the size of the responses buffered by ConditionalMiddleware to compute the
+// ETag, larger responses are sent without ETag
+var ETagBufferSize = 1 << 20
+
+// SetETag sets the ETag of the response and evaluates the conditional headers of the request, true
+// means the request was answered with 304 Not Modified or 412 Precondition Failed and the handler
+// should return, ex: optimistic concurrency with If-Match
+//
+//	if c.SetETag(strconv.Itoa(product.Version), false) {
+//		return
+//	}
+func (c *Context) SetETag(etag string, weak bool) bool {
+	c.Response.Header().Set("ETag", formatETag(etag, weak))
+	return c.evaluatePreconditions()
+}
+
+// SetLastModified sets the Last-Modified of the response and evaluates the conditional headers
+// of the request, true means the request was answered, see SetETag
+func (c *Context) SetLastModified(modified time.Time) bool {
+	if !modified.IsZero() {
+		c.Response.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	return c.evaluatePreconditions()
+}
+
+func (c *Context) evaluatePreconditions() bool {
+	switch preconditionStatus(c.Request, c.Response.Header()) {
+	case http.StatusNotModified:
+		writeNotModified(c.Response)
+		return true
+	case http.StatusPreconditionFailed:
+		_ = c.SendTextWithStatus(http.StatusPreconditionFailed, http.StatusText(http.StatusPreconditionFailed))
+		return true
+	}
+	return false
+}
+
+// ConditionalMiddleware computes the ETag of the successful GET and HEAD responses from the body,
+// weak selects weak ETags, and answers 304 when the ETag matches If-None-Match or the
+// Last-Modified set by the handler satisfies If-Modified-Since. HEAD requests run the next
+// handlers as GET and the body is discarded, so both get the same ETag. Responses with an ETag set
+// by the handler, flushed responses and responses larger than ETagBufferSize are sent as they are.
+//
+//	kernel.AddMiddleware(cloudy.ConditionalMiddleware(false))
+func ConditionalMiddleware(weak bool) HandlerFunc {
+	return func(c *Context) {
+		request := c.Request
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		head := request.Method == http.MethodHead
+		if head {
+			c.Request = request.WithContext(request.Context())
+			c.Request.Method = http.MethodGet
+		}
+
+		response := c.Response
+		writer := &etagWriter{w: response, request: request, weak: weak, head: head, buffering: true}
+		c.Response = writer
+		defer func() {
+			c.Request, c.Response = request, response
+			writer.finish()
+		}()
+		c.Next()
+	}
+}
+
+// etagWriter buffers a 200 response to compute the ETag, other responses pass through, the
+// body of HEAD requests is discarded
+type etagWriter struct {
+	w         http.ResponseWriter
+	request   *http.Request
+	weak      bool
+	head      bool
+	status    int
+	buffer    bytes.Buffer
+	buffering bool
+}
+
+func (ew *etagWriter) Header() http.Header {
+	return ew.w.Header()
+}
+
+func (ew *etagWriter) WriteHeader(status int) {
+	if !ew.buffering || ew.status != 0 {
+		return
+	}
+	// informational responses are sent as they come
+	if status < 200 {
+		ew.w.WriteHeader(status)
+		return
+	}
+	ew.status = status
+	if status != http.StatusOK || ew.w.Header().Get("ETag") != "" {
+		ew.passThrough()
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if !ew.buffering {
+		return ew.write(b)
+	}
+	if ew.buffer.Len()+len(b) > ETagBufferSize {
+		if err := ew.passThrough(); err != nil {
+			return 0, err
+		}
+		return ew.write(b)
+	}
+	return ew.buffer.Write(b)
+}
+
+func (ew *etagWriter) write(b []byte) (int, error) {
+	if ew.head {
+		return len(b), nil
+	}
+	return ew.w.Write(b)
+}
+
+// Flush sends the response without ETag, streamed responses are not delayed
+func (ew *etagWriter) Flush() {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	ew.passThrough()
+	if flusher, ok := ew.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, see http.ResponseController
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.w
+}
+
+// passThrough stops buffering, the header and the buffered body are sent
+func (ew *etagWriter) passThrough() error {
+	if !ew.buffering {
+		return nil
+	}
+	ew.buffering = false
+	ew.w.WriteHeader(ew.status)
+	if ew.buffer.Len() == 0 {
+		return nil
+	}
+	_, err := ew.write(ew.buffer.Bytes())
+	ew.buffer.Reset()
+	return err
+}
+
+// finish computes the ETag of the buffered response and answers the conditional request, the
+// responses not written by the handlers are left to the middlewares before
+func (ew *etagWriter) finish() {
+	if !ew.buffering || ew.status == 0 {
+		return
+	}
+	header := ew.w.Header()
+	if header.Get("ETag") == "" {
+		sum := sha256.Sum256(ew.buffer.Bytes())
+		header.Set("ETag", formatETag(hex.EncodeToString(sum[:16]), ew.weak))
+	}
+
+	if preconditionStatus(ew.request, header) == http.StatusNotModified {
+		ew.buffering = false
+		writeNotModified(ew.w)
+		return
+	}
+	ew.passThrough()
+}
+
+// formatETag quotes etag, an etag already quoted is kept
+func formatETag(etag string, weak bool) string {
+	etag = strings.TrimPrefix(etag, "W/")
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		etag = `"` + etag + `"`
+	}
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// preconditionStatus evaluates the conditional headers of r against the validators in header in
+// the order of RFC 9110 13.2.2, 0 means the request can proceed
+func preconditionStatus(r *http.Request, header http.Header) int {
+	etag := header.Get("ETag")
+	lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETag reports if etag is in the list of a conditional header, strong comparison requires
+// both etags to be strong, * matches any current etag
+func matchETag(list, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers with 304, the headers describing the body are removed
+func writeNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	if header.Get("ETag") != "" {
+		header.Del("Last-Modified")
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,159 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConditionalMiddleware(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddMiddleware(ConditionalMiddleware(false))
+	kernel.AddHandlerFunc("GET", "/products", func(c *Context) {
+		c.SendJSON([]string{"book", "pen"})
+	})
+	kernel.AddHandlerFunc("GET", "/missing", func(c *Context) {
+		c.SendTextWithStatus(404, "missing")
+	})
+
+	serve := func(path string, headers ...string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve("/products")
+	etag := recorder.Header().Get("ETag")
+	if recorder.Code != 200 || len(etag) < 3 || etag[0] != '"' || recorder.Body.String() != "[\"book\",\"pen\"]\n" {
+		t.Fatalf("unexpected response %d %q %v", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+	if again := serve("/products").Header().Get("ETag"); again != etag {
+		t.Errorf("the etag of the same body changed %q %q", etag, again)
+	}
+
+	recorder = serve("/products", "If-None-Match", `"other", W/`+etag)
+	if recorder.Code != 304 || recorder.Body.Len() != 0 || recorder.Header().Get("Content-Type") != "" || recorder.Header().Get("ETag") != etag {
+		t.Errorf("unexpected response %d %q %v", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+	if recorder = serve("/products", "If-None-Match", `"other"`); recorder.Code != 200 {
+		t.Errorf("unexpected status %d", recorder.Code)
+	}
+	if recorder = serve("/missing", "If-None-Match", "*"); recorder.Code != 404 || recorder.Header().Get("ETag") != "" {
+		t.Errorf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestConditionalMiddleware_Head(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddMiddleware(ConditionalMiddleware(false))
+	kernel.AddHandlerFunc("GET", "/report", func(c *Context) {
+		if c.Request.Method == http.MethodHead {
+			// handlers skipping the body of HEAD requests are run as GET
+			return
+		}
+		c.WriteString("report")
+	})
+	kernel.AddHandlerFunc("GET", "/empty", func(c *Context) {
+		c.Response.WriteHeader(200)
+	})
+
+	serve := func(method, path string, headers ...string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	etag := serve("GET", "/report").Header().Get("ETag")
+	recorder := serve("HEAD", "/report")
+	if recorder.Code != 200 || recorder.Body.Len() != 0 || recorder.Header().Get("ETag") != etag {
+		t.Errorf("unexpected HEAD response %d %q %v, GET etag %q", recorder.Code, recorder.Body.String(), recorder.Header(), etag)
+	}
+	if recorder = serve("HEAD", "/report", "If-None-Match", etag); recorder.Code != 304 {
+		t.Errorf("the etag of GET should match on HEAD, got %d", recorder.Code)
+	}
+	if recorder = serve("GET", "/empty"); recorder.Code != 200 || recorder.Header().Get("ETag") == "" {
+		t.Errorf("empty responses should have an etag %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestContext_SetETag(t *testing.T) {
+	version := 3
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("GET|PUT", "/products/1", func(c *Context) {
+		if c.SetETag(strconv.Itoa(version), false) || c.SetLastModified(modified) {
+			return
+		}
+		if c.Request.Method == http.MethodPut {
+			version++
+		}
+		c.SendTextWithStatus(200, "v"+strconv.Itoa(version))
+	})
+
+	serve := func(method string, headers ...string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/products/1", nil)
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	if recorder := serve("GET", "If-None-Match", `"3"`); recorder.Code != 304 {
+		t.Errorf("unexpected status %d", recorder.Code)
+	}
+	if recorder := serve("GET", "If-Modified-Since", modified.Format(http.TimeFormat)); recorder.Code != 304 {
+		t.Errorf("unexpected status %d", recorder.Code)
+	}
+	if recorder := serve("GET", "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)); recorder.Code != 200 {
+		t.Errorf("unexpected status %d", recorder.Code)
+	}
+
+	// optimistic concurrency, the second writer holds a stale version
+	if recorder := serve("PUT", "If-Match", `"3"`); recorder.Code != 200 || recorder.Body.String() != "v4" {
+		t.Errorf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve("PUT", "If-Match", `"3"`); recorder.Code != 412 || version != 4 {
+		t.Errorf("unexpected response %d, version %d", recorder.Code, version)
+	}
+	if recorder := serve("PUT", "If-None-Match", "*"); recorder.Code != 412 {
+		t.Errorf("unexpected status %d", recorder.Code)
+	}
+	if recorder := serve("PUT", "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)); recorder.Code != 412 {
+		t.Errorf("unexpected status %d", recorder.Code)
+	}
+}
@@ -23,7 +23,6 @@
 package cloudy
 
 import (
-	"bytes"
 	"encoding/json"
 	"github.com/CloudyKit/cloudy/link"
 	"github.com/CloudyKit/router"
@@ -35,9 +34,14 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync/atomic"
 )
 
-var ContextType = reflect.TypeOf((*Context)(nil))
+var (
+	ContextType   = reflect.TypeOf((*Context)(nil))
+	GoContextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	RequestType   = reflect.TypeOf((*http.Request)(nil))
+)
 
 // GetContext gets a Context from the registry context
 func GetContext(cdi Registry) *Context {
@@ -47,26 +51,45 @@ func GetContext(cdi Registry) *Context {
 // Context holds context information about the incoming request
 type Context struct {
 	Name     string        // The name associated with the route
+	Pattern  string        // Pattern of the route, ex: /users/:id, empty in the NotFound and MethodNotAllowed handlers
 	Registry Registry      // Dependency injection context
 	Request  *http.Request // Request data passed by the router
 	Gen      *link.URLGen
 
-	handlers []Handler
+	handlers     []Handler
+	route        Registry // route is the registry of the route, the request registry is forked from it
+	interceptors []HandlerInterceptor
+	writer       responseWriter
+	response     ResponseWriter
+
+	Response   http.ResponseWriter // Response Writer passed by the router
+	Params     router.Parameter    // Route Registry passed by the router
+	HostParams HostParams          // HostParams values captured by a wildcard host, see Kernel.Host
+	RequestID  string              // RequestID identifies the request, see RequestIDMiddleware
+	body       io.ReadCloser
+	bodyRead   int64
+	bodyLimit  int64 // bodyLimit caps the bytes read from the body, see BodyLimit
+	bodyErr    error
+	spool      *bodySpool
+	logger     atomic.Pointer[requestLogger] // logger of the request, see GetSlog
+}
 
-	Response  http.ResponseWriter // Response Writer passed by the router
-	Params    router.Parameter    // Route Registry passed by the router
-	body      io.ReadCloser
-	bodyBytes []byte
-	bodyReady bool
+// Writer returns the ResponseWriter wrapping the writer of the request, the status and the size
+// of the response are available even when Response is replaced by a middleware
+func (c *Context) Writer() ResponseWriter {
+	return c.response
 }
 
 func (c *Context) SendJSONStatusCode(statusCode int, v any) error {
 
-	c.Response.Header().Set("Content-Type", "application/json")
-	if statusCode == 0 {
-		statusCode = http.StatusOK
+	// the status and the content type can't be changed once the header is written
+	if c.response == nil || !c.response.Written() {
+		c.Response.Header().Set("Content-Type", "application/json")
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		c.Response.WriteHeader(statusCode)
 	}
-	c.Response.WriteHeader(statusCode)
 	return json.NewEncoder(c.Response).Encode(v)
 }
 
@@ -93,10 +116,59 @@ func (c *Context) Next() error {
 
 	handler := c.handlers[0]
 	c.handlers = c.handlers[1:]
-	handler.Handle(c)
+	if len(c.interceptors) > 0 {
+		c.intercept(handler, c.interceptors)
+	} else {
+		handler.Handle(c)
+	}
 	return nil
 }
 
+// HandlerInterceptor runs around the handlers advanced with Context.Next, calling next runs the
+// handler, ex: tracing opens a span around each middleware and action
+type HandlerInterceptor func(c *Context, handler Handler, next func())
+
+// Intercept adds an interceptor to the handlers not yet advanced in the request, interceptors
+// run in the order they were added
+func (c *Context) Intercept(interceptor HandlerInterceptor) {
+	c.interceptors = append(c.interceptors, interceptor)
+}
+
+func (c *Context) intercept(handler Handler, interceptors []HandlerInterceptor) {
+	if len(interceptors) == 0 {
+		handler.Handle(c)
+		return
+	}
+	interceptors[0](c, handler, func() {
+		c.intercept(handler, interceptors[1:])
+	})
+}
+
+// HasNext reports if there are handlers to advance, inside the last handler of the route it's false
+func (c *Context) HasNext() bool {
+	return len(c.handlers) > 0
+}
+
+// Replay returns a func running the handlers not yet advanced with a new request and a new Context,
+// the registry of the replay is forked from the registry of the route, the handlers already advanced
+// and the values they registered are not part of the replay, middlewares run before the handlers.
+// The func can run after the request ends, ex: the cache refreshes a stale response in the background
+// running only the action of the route.
+func (c *Context) Replay() func(w http.ResponseWriter, r *http.Request, middlewares ...Handler) {
+	name, pattern, params, handlers := c.Name, c.Pattern, c.Params, c.handlers
+	registry := c.route
+	if registry == nil {
+		registry = GetKernel(c.Registry).Registry
+	}
+	return func(w http.ResponseWriter, r *http.Request, middlewares ...Handler) {
+		replay := newRequestContext()
+		defer requestRecover(replay)
+		replay.route = registry
+		replay.Pattern = pattern
+		_ = DispatchNext(replay, name, w, r, params, registry.Fork(), append(middlewares[:len(middlewares):len(middlewares)], handlers...))
+	}
+}
+
 // WriteString writes the string txt into the the response
 func (c *Context) WriteString(txt string) (int, error) {
 	return c.Response.Write([]byte(txt))
@@ -122,6 +194,11 @@ func (c *Context) GetURLParameter(name string) string {
 	return c.Params.Get(name)
 }
 
+// GetHostParameter returns a value captured by a wildcard host, ex: tenant in {tenant}.example.com
+func (c *Context) GetHostParameter(name string) string {
+	return c.HostParams.Get(name)
+}
+
 // GetPostValue  returns a form value from the request, GetPostValue is shortcut for Context.Request.Form.Get method
 func (c *Context) GetPostValue(name string) string {
 	if c.Request.PostForm == nil {
@@ -146,50 +223,11 @@ func (c *Context) GetCookieValue(name string) (value string) {
 	return
 }
 
-type contextBodyReader struct {
-	c       *Context
-	buffer  bytes.Reader
-	started bool
-}
-
-func (c *Context) GetBodyBytes() ([]byte, error) {
-	var err error
-	if !c.bodyReady {
-		c.bodyBytes, err = io.ReadAll(c.body)
-		if err != nil {
-			return nil, err
-		}
-		c.bodyReady = true
-	}
-	return c.bodyBytes, nil
-}
-
-func (c *contextBodyReader) Read(p []byte) (int, error) {
-	if !c.started {
-		bodyBytes, err := c.c.GetBodyBytes()
-		if err != nil {
-			return 0, err
-		}
-		c.started = true
-		c.buffer.Reset(bodyBytes)
-	}
-	return c.buffer.Read(p)
-}
-
-func (c *contextBodyReader) Close() error {
-	return c.c.body.Close()
-}
-
-// GetBodyReader returns bytes bodyReader
-func (c *Context) GetBodyReader() io.ReadCloser {
-	return &contextBodyReader{
-		c: c,
-	}
-}
-
 func (c *Context) SendTextWithStatus(statusCode int, content string) error {
-	c.Response.Header().Set("Content-Type", "text/plain")
-	c.Response.WriteHeader(statusCode)
+	if c.response == nil || !c.response.Written() {
+		c.Response.Header().Set("Content-Type", "text/plain")
+		c.Response.WriteHeader(statusCode)
+	}
 	_, err := c.Response.Write([]byte(content))
 	return err
 }
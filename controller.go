@@ -26,7 +26,6 @@ import (
 	"github.com/CloudyKit/cloudy/event"
 	"github.com/CloudyKit/cloudy/link"
 	"reflect"
-	"regexp"
 	"sync"
 )
 
@@ -80,6 +79,7 @@ func (kernel *Kernel) AddControllers(contexts ...Controller) {
 		// injects parent url generator
 		registry.Autowire(myURLGen)
 		myURLGen.urlGen = kernel.URLGen
+		myURLGen.prefix = kernel.namePrefix()
 
 		registry.WithTypeAndValue(link.URLGenType, myURLGen)
 
@@ -104,7 +104,7 @@ func (kernel *Kernel) AddControllers(contexts ...Controller) {
 		}
 
 		controller.Mx(mapper)
-		myURLGen.id = mapper.Name + "."
+		myURLGen.id = kernel.namePrefix() + mapper.Name + "."
 	}
 }
 
@@ -140,8 +140,6 @@ func (handler *controllerHandler) Handle(c *Context) {
 	handler.pool.Put(ii)
 }
 
-var acRegex = regexp.MustCompile("/[:*][^/]+")
-
 func (mx *Mapper) BindAction(method, path, action string, filters ...Handler) {
 	methodByName, isPtr := mx.typ.MethodByName(action)
 	if !isPtr {
@@ -151,14 +149,10 @@ func (mx *Mapper) BindAction(method, path, action string, filters ...Handler) {
 		}
 	}
 
-	mx.app.URLGen[mx.Name+"."+action] = acRegex.ReplaceAllStringFunc(mx.app.Prefix+mx.Prefix+path, func(st string) string {
-		if st[1] == '*' {
-			return "%v"
-		}
-		return "/%v"
-	})
+	// the route pattern is kept, parameters are filled by link.Expand when the url is generated
+	mx.app.URLGen[mx.app.namePrefix()+mx.Name+"."+action] = mx.app.Prefix + mx.Prefix + path
 
-	mx.app.AddHandlerContextName(mx.Registry, mx.Name, method, mx.Prefix+path, &controllerHandler{
+	mx.app.addRoute(mx.Registry, mx.Name, mx.app.namePrefix()+mx.Name+"."+action, method, mx.Prefix+path, &controllerHandler{
 		pool:      mx.pool,
 		isPtr:     isPtr,
 		zeroValue: mx.zeroValue,
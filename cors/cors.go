@@ -0,0 +1,315 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package cors implements Cross-Origin Resource Sharing for cloudy applications.
+//
+//	kernel.AddComponents(&cors.Component{Policy: cors.Policy{
+//		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.com"},
+//		AllowCredentials: true,
+//		MaxAge:           time.Hour,
+//	}})
+//
+// The component answers preflight requests for every route, including routes that register only
+// GET. A route can override the policy adding a Policy as middleware, ex:
+//
+//	func (c *Public) Mx(mx *cloudy.Mapper) {
+//		mx.AddMiddleware(&cors.Policy{AllowedOrigins: []string{"*"}})
+//		mx.BindAction("GET", "/feed", "Feed")
+//	}
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerOrigin           = "Origin"
+	headerRequestMethod    = "Access-Control-Request-Method"
+	headerRequestHeaders   = "Access-Control-Request-Headers"
+	headerAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAllowCredentials = "Access-Control-Allow-Credentials"
+	headerAllowMethods     = "Access-Control-Allow-Methods"
+	headerAllowHeaders     = "Access-Control-Allow-Headers"
+	headerExposeHeaders    = "Access-Control-Expose-Headers"
+	headerMaxAge           = "Access-Control-Max-Age"
+)
+
+// DefaultAllowedHeaders are the request headers allowed when Policy.AllowedHeaders is empty
+var DefaultAllowedHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type", "X-Requested-With"}
+
+// Policy describes which cross-origin requests are allowed
+type Policy struct {
+	// AllowedOrigins exact origins, origins with a wildcard, ex: https://*.example.com, or * for any origin
+	AllowedOrigins []string
+	// AllowedOriginPatterns origins matching one of the patterns are allowed
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowedMethods methods allowed in preflight requests, by default any method with a route is allowed
+	AllowedMethods []string
+	// AllowedHeaders request headers allowed in preflight requests, default DefaultAllowedHeaders, * allows any header
+	AllowedHeaders []string
+	// ExposedHeaders response headers exposed to the client
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers, it can't be combined with the * origin
+	AllowCredentials bool
+	// MaxAge how long the preflight response can be cached
+	MaxAge time.Duration
+
+	once sync.Once
+	err  error
+}
+
+// validate rejects the policies allowing any origin with credentials, any site could read the
+// responses of the users of the application
+func (policy *Policy) validate() error {
+	if !policy.AllowCredentials {
+		return nil
+	}
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == "*" {
+			return errors.New("cors: the * origin can't be allowed with credentials, list the allowed origins")
+		}
+	}
+	return nil
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, empty when the origin is not allowed
+// mustValidate panics when the policy is invalid, the policy is validated once
+func (policy *Policy) mustValidate() {
+	policy.once.Do(func() {
+		policy.err = policy.validate()
+	})
+	if policy.err != nil {
+		panic(policy.err)
+	}
+}
+
+func (policy *Policy) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if matchOrigin(allowed, origin) {
+			return origin
+		}
+	}
+	for _, pattern := range policy.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// matchOrigin matches origin against an exact origin or an origin with one wildcard
+func matchOrigin(allowed, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(allowed, "*")
+	if !wildcard {
+		return strings.EqualFold(allowed, origin)
+	}
+	origin = strings.ToLower(origin)
+	prefix, suffix = strings.ToLower(prefix), strings.ToLower(suffix)
+	return len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+func (policy *Policy) allowMethod(method string) bool {
+	if len(policy.AllowedMethods) == 0 {
+		return true
+	}
+	for _, allowed := range policy.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders reports if all the requested headers are allowed
+func (policy *Policy) allowHeaders(requested []string) bool {
+	allowedHeaders := policy.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = DefaultAllowedHeaders
+	}
+
+	for _, header := range requested {
+		allowed := false
+		for _, allowedHeader := range allowedHeaders {
+			if allowedHeader == "*" || strings.EqualFold(allowedHeader, header) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// apply sets the headers of an actual cross-origin request, headers set by other policies are replaced
+func (policy *Policy) apply(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Del(headerAllowOrigin)
+	header.Del(headerAllowCredentials)
+	header.Del(headerExposeHeaders)
+	addVary(header, headerOrigin)
+
+	allowOrigin := policy.allowOrigin(r.Header.Get(headerOrigin))
+	if allowOrigin == "" {
+		return
+	}
+	header.Set(headerAllowOrigin, allowOrigin)
+	if policy.AllowCredentials {
+		header.Set(headerAllowCredentials, "true")
+	}
+	if len(policy.ExposedHeaders) > 0 {
+		header.Set(headerExposeHeaders, strings.Join(policy.ExposedHeaders, ", "))
+	}
+}
+
+// preflight answers a preflight request, the allow headers are sent only when the request is allowed
+func (policy *Policy) preflight(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	addVary(header, headerOrigin, headerRequestMethod, headerRequestHeaders)
+
+	method := r.Header.Get(headerRequestMethod)
+	requestedHeaders := splitHeaders(r.Header.Values(headerRequestHeaders))
+
+	allowOrigin := policy.allowOrigin(r.Header.Get(headerOrigin))
+	if allowOrigin != "" && policy.allowMethod(method) && policy.allowHeaders(requestedHeaders) {
+		header.Set(headerAllowOrigin, allowOrigin)
+		header.Set(headerAllowMethods, method)
+		if len(requestedHeaders) > 0 {
+			header.Set(headerAllowHeaders, strings.Join(requestedHeaders, ", "))
+		}
+		if policy.AllowCredentials {
+			header.Set(headerAllowCredentials, "true")
+		}
+		if policy.MaxAge > 0 {
+			header.Set(headerMaxAge, strconv.Itoa(int(policy.MaxAge/time.Second)))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handle applies the policy to the requests of the route, replacing the policy of the Component,
+// preflight requests of the route are answered by the Component with this policy. The policy is
+// validated as in Component.Bootstrap, the first request of the route panics when the policy
+// allows any origin with credentials.
+func (policy *Policy) Handle(c *cloudy.Context) {
+	policy.mustValidate()
+	if c.Request.Header.Get(headerOrigin) != "" {
+		policy.apply(c.Response, c.Request)
+	}
+	c.Next()
+}
+
+// Component applies the Policy to all requests of the kernel and answers preflight requests,
+// the routes are matched by the router of the kernel. Bootstrap panics when the policy allows
+// any origin with credentials, or when the kernel has routes, routes added before the component
+// would not run its middleware.
+type Component struct {
+	Policy
+	matchRoute func(method, path string) (cloudy.Route, bool)
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	component.mustValidate()
+	root := kernel.Root()
+	if routes := root.Routes(); len(routes) > 0 {
+		panic(fmt.Errorf("cors: add the Component before the routes of the kernel, %s %s was added at %s", routes[0].Method, routes[0].Path, routes[0].Source))
+	}
+	component.matchRoute = root.MatchRoute
+	root.AddMiddleware(component)
+}
+
+func (component *Component) Handle(c *cloudy.Context) {
+	r := c.Request
+	if r.Header.Get(headerOrigin) == "" {
+		c.Next()
+		return
+	}
+
+	if r.Method == http.MethodOptions && r.Header.Get(headerRequestMethod) != "" {
+		method := r.Header.Get(headerRequestMethod)
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+		if route, found := component.matchRoute(method, r.URL.Path); found {
+			routePolicy(route, &component.Policy).preflight(c.Response, r)
+			return
+		}
+	}
+
+	component.apply(c.Response, r)
+	c.Next()
+}
+
+// routePolicy returns the last Policy in the handlers of the route or policy
+func routePolicy(route cloudy.Route, policy *Policy) *Policy {
+	for _, handler := range route.Handlers() {
+		if routePolicy, ok := handler.(*Policy); ok {
+			routePolicy.mustValidate()
+			policy = routePolicy
+		}
+	}
+	return policy
+}
+
+func splitHeaders(values []string) (headers []string) {
+	for _, value := range values {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, header)
+			}
+		}
+	}
+	return
+}
+
+// addVary adds the names to the Vary header, names already present are not repeated
+func addVary(header http.Header, names ...string) {
+	present := splitHeaders(header.Values("Vary"))
+	for _, name := range names {
+		found := false
+		for _, value := range present {
+			if strings.EqualFold(value, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			header.Add("Vary", name)
+			present = append(present, name)
+		}
+	}
+}
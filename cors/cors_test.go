@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cors
+
+import (
+	"github.com/CloudyKit/cloudy"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type feed struct {
+	Context *cloudy.Context
+}
+
+func (f *feed) Mx(mx *cloudy.Mapper) {
+	mx.Name = "Feed"
+	mx.AddMiddleware(&Policy{AllowedOrigins: []string{"*"}})
+	mx.BindAction("GET", "/feed", "Index")
+}
+
+func (f *feed) Index() {
+	f.Context.WriteString("feed")
+}
+
+func newKernel() *cloudy.Kernel {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{Policy: Policy{
+		AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+		AllowedHeaders:        []string{"Content-Type", "Authorization"},
+		ExposedHeaders:        []string{"X-Total"},
+		AllowCredentials:      true,
+		MaxAge:                10 * time.Minute,
+	}})
+	kernel.AddHandlerFunc("GET", "/users", func(c *cloudy.Context) {
+		c.WriteString("users")
+	})
+	kernel.AddControllers(&feed{})
+	return kernel
+}
+
+func serve(kernel *cloudy.Kernel, method, path string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	for i := 0; i < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestComponent_ActualRequest(t *testing.T) {
+	kernel := newKernel()
+
+	for _, origin := range []string{"https://app.example.com", "https://a.example.org", "http://localhost:3000"} {
+		recorder := serve(kernel, "GET", "/users", "Origin", origin)
+		header := recorder.Header()
+		if header.Get(headerAllowOrigin) != origin || header.Get(headerAllowCredentials) != "true" || header.Get(headerExposeHeaders) != "X-Total" {
+			t.Errorf("%s: unexpected headers %v", origin, header)
+		}
+		if header.Get("Vary") != "Origin" || recorder.Body.String() != "users" {
+			t.Errorf("%s: unexpected response %v %q", origin, header, recorder.Body.String())
+		}
+	}
+
+	for _, origin := range []string{"https://evil.com", "https://example.org", "https://app.example.com.evil.com"} {
+		recorder := serve(kernel, "GET", "/users", "Origin", origin)
+		if allowed := recorder.Header().Get(headerAllowOrigin); allowed != "" {
+			t.Errorf("%s: origin allowed %q", origin, allowed)
+		}
+	}
+}
+
+func TestComponent_Preflight(t *testing.T) {
+	kernel := newKernel()
+
+	recorder := serve(kernel, "OPTIONS", "/users",
+		"Origin", "https://app.example.com",
+		headerRequestMethod, "GET",
+		headerRequestHeaders, "content-type, authorization",
+	)
+	header := recorder.Header()
+	if recorder.Code != 204 || header.Get(headerAllowOrigin) != "https://app.example.com" || header.Get(headerAllowMethods) != "GET" {
+		t.Fatalf("unexpected preflight response %d %v", recorder.Code, header)
+	}
+	if header.Get(headerAllowHeaders) != "content-type, authorization" || header.Get(headerMaxAge) != "600" {
+		t.Fatalf("unexpected preflight headers %v", header)
+	}
+	if vary := header.Values("Vary"); len(vary) != 3 {
+		t.Fatalf("unexpected vary %v", vary)
+	}
+
+	recorder = serve(kernel, "OPTIONS", "/users", "Origin", "https://app.example.com", headerRequestMethod, "GET", headerRequestHeaders, "X-Custom")
+	if recorder.Header().Get(headerAllowOrigin) != "" {
+		t.Fatalf("header not allowed: unexpected headers %v", recorder.Header())
+	}
+
+	recorder = serve(kernel, "OPTIONS", "/users", "Origin", "https://app.example.com", headerRequestMethod, "DELETE")
+	if recorder.Header().Get(headerAllowMethods) != "" || recorder.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Fatalf("method without route: unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestPolicy_RouteOverride(t *testing.T) {
+	kernel := newKernel()
+
+	recorder := serve(kernel, "GET", "/feed", "Origin", "https://other.com")
+	if recorder.Header().Get(headerAllowOrigin) != "*" || recorder.Header().Get(headerAllowCredentials) != "" || recorder.Body.String() != "feed" {
+		t.Fatalf("unexpected response %v %q", recorder.Header(), recorder.Body.String())
+	}
+
+	recorder = serve(kernel, "OPTIONS", "/feed", "Origin", "https://other.com", headerRequestMethod, "GET")
+	if recorder.Code != 204 || recorder.Header().Get(headerAllowOrigin) != "*" {
+		t.Fatalf("unexpected preflight response %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestPolicy_WildcardWithCredentials(t *testing.T) {
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected Bootstrap to reject the * origin with credentials")
+			}
+		}()
+		cloudy.NewKernel().AddComponents(&Component{Policy: Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}})
+	}()
+
+	kernel := cloudy.NewKernel()
+	kernel.AddHandlerFunc("GET", "/account", func(c *cloudy.Context) {
+		c.WriteString("account")
+	}, &Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the route policy to reject the * origin with credentials")
+		}
+	}()
+	serve(kernel, "GET", "/account", "Origin", "https://evil.com")
+}
+
+func TestComponent_AfterRoutes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Bootstrap to reject a kernel with routes")
+		}
+	}()
+	kernel := cloudy.NewKernel()
+	kernel.AddHandlerFunc("GET", "/users", func(c *cloudy.Context) {})
+	kernel.AddComponents(&Component{Policy: Policy{AllowedOrigins: []string{"https://app.example.com"}}})
+}
@@ -28,25 +28,31 @@ import (
 
 // BindGetForm decodes the request url values into target
 func (c *Context) BindGetForm(target interface{}) error {
-	c.Request.Body = c.GetBodyReader()
 	if c.Request.Form == nil {
-		c.Request.ParseForm()
+		c.parseForm()
 	}
 	return formamDecoder(c.Request.Form, target)
 }
 
 // BindForm decodes request post data into target
 func (c *Context) BindForm(target interface{}) error {
-	c.Request.Body = c.GetBodyReader()
 	if c.Request.PostForm == nil {
-		c.Request.ParseForm()
+		c.parseForm()
 	}
 	return formamDecoder(c.Request.PostForm, target)
 }
 
 // BindJSON decodes request body as json into the target
 func (c *Context) BindJSON(target interface{}) error {
-	return json.NewDecoder(c.GetBodyReader()).Decode(target)
+	return json.NewDecoder(c.bindBody()).Decode(target)
+}
+
+// parseForm parses the form of the request from bindBody, Request.Body is left as it was
+func (c *Context) parseForm() {
+	body := c.Request.Body
+	c.Request.Body = c.bindBody()
+	_ = c.Request.ParseForm()
+	c.Request.Body = body
 }
 
 // todo: add a generic bind func which will decode values conforming with
@@ -0,0 +1,207 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/CloudyKit/cloudy/registry"
+	"reflect"
+	"sync"
+)
+
+// Message is the unit exchanged by transports, Payload holds the json encoded event payload
+type Message struct {
+	Event   string          `json:"event"`
+	Origin  string          `json:"origin,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Transport carries messages across process boundaries
+type Transport interface {
+	// Publish sends the message to the other peers
+	Publish(message Message) error
+	// Subscribe registers handler to be called for every message received
+	Subscribe(handler func(message Message)) error
+	// Close releases the transport resources
+	Close() error
+}
+
+// TypeRegistry maps event names to payload types, used to decode incoming payloads
+type TypeRegistry struct {
+	mx    sync.RWMutex
+	types map[string]reflect.Type
+}
+
+// NewTypeRegistry creates an empty TypeRegistry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: make(map[string]reflect.Type)}
+}
+
+// Register maps eventName to the type of payload, payload is usually a nil pointer
+// ex: types.Register("order.created", (*OrderCreated)(nil))
+func (types *TypeRegistry) Register(eventName string, payload Payload) {
+	typ := reflect.TypeOf(payload)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("event.TypeRegistry: payload type %v is not a pointer to struct", typ))
+	}
+	types.mx.Lock()
+	types.types[eventName] = typ.Elem()
+	types.mx.Unlock()
+}
+
+// Lookup reports if eventName is registered
+func (types *TypeRegistry) Lookup(eventName string) bool {
+	types.mx.RLock()
+	_, found := types.types[eventName]
+	types.mx.RUnlock()
+	return found
+}
+
+// Decode decodes data into a new payload of the type registered for eventName
+func (types *TypeRegistry) Decode(eventName string, data []byte) (Payload, error) {
+	types.mx.RLock()
+	typ, found := types.types[eventName]
+	types.mx.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("event.TypeRegistry: event %q is not registered", eventName)
+	}
+
+	payload := newPayload(typ).Interface().(Payload)
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Bridge forwards events dispatched locally to a transport and dispatches locally the events
+// received from the transport.
+//
+//	bridge := event.NewBridge(event.GetDispatcher(kernel.Registry), transport, types)
+//	bridge.Forward("order.created|order.paid")
+//	err := bridge.Start()
+type Bridge struct {
+	ID       string             // ID identifies this bridge in the messages it publishes
+	Registry registry.Interface // Registry passed to the incoming events
+	OnError  func(err error)    // OnError is called when an event can't be published or decoded
+
+	dispatcher *Dispatcher
+	transport  Transport
+	types      *TypeRegistry
+
+	mx       sync.RWMutex
+	closed   bool
+	incoming sync.Map
+}
+
+// NewBridge creates a bridge between dispatcher and transport, types is used to decode
+// the incoming payloads, only events registered in types are dispatched locally
+func NewBridge(dispatcher *Dispatcher, transport Transport, types *TypeRegistry) *Bridge {
+	if dispatcher == nil {
+		dispatcher = sub
+	}
+	if types == nil {
+		types = NewTypeRegistry()
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return &Bridge{
+		ID:         hex.EncodeToString(id),
+		dispatcher: dispatcher,
+		transport:  transport,
+		types:      types,
+	}
+}
+
+// Forward publishes the events with the given names into the transport, multiple names can be
+// separated by |, see Dispatcher.Subscribe
+func (bridge *Bridge) Forward(events string) {
+	bridge.dispatcher.Subscribe(events, func(payload Payload) {
+		bridge.forward(payload)
+	})
+}
+
+func (bridge *Bridge) forward(payload Payload) {
+	bridge.mx.RLock()
+	closed := bridge.closed
+	bridge.mx.RUnlock()
+
+	// events being dispatched by this bridge are not sent back
+	if _, isIncoming := bridge.incoming.Load(payload); closed || isIncoming {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err == nil {
+		err = bridge.transport.Publish(Message{Event: payload.EventName(), Origin: bridge.ID, Payload: data})
+	}
+	if err != nil {
+		bridge.error(err)
+	}
+}
+
+// Start subscribes the bridge to the transport
+func (bridge *Bridge) Start() error {
+	return bridge.transport.Subscribe(bridge.receive)
+}
+
+func (bridge *Bridge) receive(message Message) {
+	bridge.mx.RLock()
+	closed := bridge.closed
+	bridge.mx.RUnlock()
+
+	if closed || message.Origin == bridge.ID || !bridge.types.Lookup(message.Event) {
+		return
+	}
+
+	payload, err := bridge.types.Decode(message.Event, message.Payload)
+	if err != nil {
+		bridge.error(err)
+		return
+	}
+
+	bridge.incoming.Store(payload, struct{}{})
+	defer bridge.incoming.Delete(payload)
+
+	if _, err = bridge.dispatcher.Dispatch(bridge.Registry, message.Event, payload); err != nil {
+		bridge.error(err)
+	}
+}
+
+func (bridge *Bridge) error(err error) {
+	if bridge.OnError != nil {
+		bridge.OnError(err)
+	}
+}
+
+// Close stops forwarding and receiving events and closes the transport
+func (bridge *Bridge) Close() error {
+	bridge.mx.Lock()
+	bridge.closed = true
+	bridge.mx.Unlock()
+	return bridge.transport.Close()
+}
@@ -0,0 +1,136 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"testing"
+	"time"
+)
+
+func newBridgedDispatcher(t *testing.T, transport Transport) (*Dispatcher, *Bridge) {
+	types := NewTypeRegistry()
+	types.Register("order.created", (*orderCreated)(nil))
+	types.Register("user.renamed", (*ValueEvent)(nil))
+
+	events := NewDispatcher()
+	bridge := NewBridge(events, transport, types)
+	bridge.OnError = func(err error) {
+		t.Error(err)
+	}
+	bridge.Forward("order.created|user.renamed")
+	if err := bridge.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return events, bridge
+}
+
+func TestBridge_Memory(t *testing.T) {
+	broker := NewMemoryBroker()
+
+	a, bridgeA := newBridgedDispatcher(t, broker.Transport())
+	defer bridgeA.Close()
+	b, bridgeB := newBridgedDispatcher(t, broker.Transport())
+	defer bridgeB.Close()
+
+	var receivedA, receivedB int
+	a.Subscribe("order.created", func(e *orderCreated) {
+		receivedA++
+	})
+	b.Subscribe("order.created", func(e *orderCreated) {
+		receivedB = e.OrderID
+	})
+
+	a.Dispatch(nil, "order.created", &orderCreated{OrderID: 42})
+
+	if receivedB != 42 {
+		t.Fatalf("event was not bridged, got order %d", receivedB)
+	}
+	// the event dispatched in b must not come back to a
+	if receivedA != 1 {
+		t.Fatalf("event was dispatched %d times in the origin", receivedA)
+	}
+}
+
+func TestBridge_EmbeddedEventPointer(t *testing.T) {
+	broker := NewMemoryBroker()
+
+	a, bridgeA := newBridgedDispatcher(t, broker.Transport())
+	defer bridgeA.Close()
+	b, bridgeB := newBridgedDispatcher(t, broker.Transport())
+	defer bridgeB.Close()
+
+	var received interface{}
+	b.Subscribe("user.renamed", func(e *ValueEvent) {
+		received = e.Value
+	})
+
+	a.Dispatch(nil, "user.renamed", &ValueEvent{Event: &Event{}, Value: "ana"})
+
+	if received != "ana" {
+		t.Fatalf("event was not bridged, got %v", received)
+	}
+}
+
+func TestBridge_TCP(t *testing.T) {
+	server, err := ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := DialTCP(server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, bridgeA := newBridgedDispatcher(t, server)
+	defer bridgeA.Close()
+	b, bridgeB := newBridgedDispatcher(t, client)
+	defer bridgeB.Close()
+
+	received := make(chan *orderCreated, 1)
+	a.Subscribe("order.created", func(e *orderCreated) {
+		received <- e
+	})
+
+	// waits the server to register the client connection
+	deadline := time.Now().Add(time.Second)
+	for {
+		server.mx.RLock()
+		connected := len(server.conns)
+		server.mx.RUnlock()
+		if connected == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	b.Dispatch(nil, "order.created", &orderCreated{OrderID: 7, Total: 9.5})
+
+	select {
+	case e := <-received:
+		if e.OrderID != 7 || e.Total != 9.5 || e.EventName() != "order.created" {
+			t.Fatalf("unexpected payload %#v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not received over tcp")
+	}
+}
@@ -23,6 +23,7 @@
 package event
 
 import (
+	"context"
 	"github.com/CloudyKit/cloudy/registry"
 )
 
@@ -55,6 +56,15 @@ func Dispatch(registry registry.Interface, eventName string, event Payload) (boo
 	return sub.Dispatch(registry, eventName, event)
 }
 
+func DispatchContext(ctx context.Context, registry registry.Interface, eventName string, event Payload) (bool, error) {
+	if registry != nil {
+		if sub := GetDispatcher(registry); sub != nil {
+			return sub.DispatchContext(ctx, registry, eventName, event)
+		}
+	}
+	return sub.DispatchContext(ctx, registry, eventName, event)
+}
+
 func Reset(global registry.Interface, groupName string) bool {
 	if global != nil {
 		if sub := GetDispatcher(global); sub != nil {
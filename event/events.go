@@ -23,15 +23,20 @@
 package event
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/CloudyKit/cloudy/registry"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-var eventPayloadType = reflect.TypeOf((*Payload)(nil)).Elem()
+var (
+	eventPayloadType = reflect.TypeOf((*Payload)(nil)).Elem()
+	goContextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
 
 var (
 	_ = Payload(&Event{})
@@ -43,22 +48,58 @@ type Event struct {
 	canceled    bool
 	unsubscribe bool
 	registry    registry.Interface
+	ctx         context.Context
 }
 
 type Payload interface {
-	init(registry registry.Interface, eventName string)
+	init(ctx context.Context, registry registry.Interface, eventName string)
 	error() error
 	unsubscribed() bool
 	WasCanceled() bool
 	Registry() registry.Interface
+	Context() context.Context
 	EventName() string
 	Cancel()
 	CancelWithError(err error)
 	CancelWithErrorf(format string, v ...interface{})
 }
 
-func (e *Event) init(registry registry.Interface, eventName string) {
-	*e = Event{registry: registry, eventName: eventName}
+func (e *Event) init(ctx context.Context, registry registry.Interface, eventName string) {
+	*e = Event{ctx: resolveContext(ctx, registry), registry: registry, eventName: eventName}
+}
+
+// newPayload returns a pointer to a new value of the struct typ, the embedded *Event fields,
+// ex: ValueEvent, are allocated so the decoded payloads can be dispatched
+func newPayload(typ reflect.Type) reflect.Value {
+	value := reflect.New(typ)
+	allocateEvents(value.Elem())
+	return value
+}
+
+func allocateEvents(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.Anonymous {
+			continue
+		}
+		if field.Type == eventPtrType {
+			value.Field(i).Set(reflect.New(eventType))
+		} else if field.Type.Kind() == reflect.Struct && field.Type != eventType {
+			allocateEvents(value.Field(i))
+		}
+	}
+}
+
+// resolveContext returns ctx or, when ctx is nil, the context provided in the registry
+func resolveContext(ctx context.Context, registry registry.Interface) context.Context {
+	if ctx == nil && registry != nil {
+		// the request context is provided in the registry while handling a request
+		ctx, _ = registry.LoadType(goContextType).(context.Context)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
 }
 
 func (e *Event) WasCanceled() bool {
@@ -69,6 +110,15 @@ func (e *Event) Registry() registry.Interface {
 	return e.registry
 }
 
+// Context returns the context the event was dispatched with, when dispatched while handling
+// a request this is the request context
+func (e *Event) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
 func (e *Event) EventName() string {
 	return e.eventName
 }
@@ -120,8 +170,14 @@ type Dispatcher struct {
 	parent        *Dispatcher
 	mx            sync.RWMutex
 	subscriptions []subscriptionGroups
+	outbox        atomic.Pointer[Outbox]
+	observers     atomic.Pointer[[]Observer]
 }
 
+// Observer is called when an event is dispatched, the returned context is the context of the
+// event and done is called with the result of the dispatch, ex: metrics and tracing, see Observe
+type Observer func(ctx context.Context, eventName string) (eventCtx context.Context, done func(canceled bool, err error))
+
 func (dispatcher *Dispatcher) Inherit() *Dispatcher {
 	return &Dispatcher{parent: dispatcher}
 }
@@ -230,6 +286,12 @@ func (dispatcher *Dispatcher) emit(eventName string, event Payload) (canceled bo
 			if group.handlers[i] != nil {
 				v := reflect.ValueOf(group.handlers[i])
 				if _type.AssignableTo(v.Type().In(0)) {
+					// stops the propagation when the client went away or the deadline was reached
+					if err = event.Context().Err(); err != nil {
+						event.CancelWithError(err)
+						canceled = true
+						return
+					}
 					v.Call(_arg)
 					if event.unsubscribed() {
 						hasUnsubscribes = true
@@ -256,10 +318,70 @@ func (dispatcher *Dispatcher) emit(eventName string, event Payload) (canceled bo
 	return
 }
 
+// findOutbox returns the outbox attached to this dispatcher or to the closest parent
+func (dispatcher *Dispatcher) findOutbox() *Outbox {
+	for ; dispatcher != nil; dispatcher = dispatcher.parent {
+		if outbox := dispatcher.outbox.Load(); outbox != nil {
+			return outbox
+		}
+	}
+	return nil
+}
+
+// Observe adds an observer to the dispatcher, events dispatched by this dispatcher or by the
+// dispatchers inheriting from it are observed
+func (dispatcher *Dispatcher) Observe(observer Observer) {
+	dispatcher.mx.Lock()
+	defer dispatcher.mx.Unlock()
+
+	var observers []Observer
+	if current := dispatcher.observers.Load(); current != nil {
+		observers = append(observers, *current...)
+	}
+	observers = append(observers, observer)
+	dispatcher.observers.Store(&observers)
+}
+
+// findObservers returns the observers of this dispatcher and of the parents
+func (dispatcher *Dispatcher) findObservers() (observers []Observer) {
+	for ; dispatcher != nil; dispatcher = dispatcher.parent {
+		if current := dispatcher.observers.Load(); current != nil {
+			observers = append(observers, *current...)
+		}
+	}
+	return
+}
+
 // Dispatch emits an event in the given eventName with the specified key,
 // calling *Event.Cancel() will stop the event propagation, calling *Event.CancelWithError(err) will flag an error
 // and cancel the event propagation
 func (dispatcher *Dispatcher) Dispatch(registry registry.Interface, eventName string, event Payload) (bool, error) {
-	event.init(registry, eventName)
+	return dispatcher.DispatchContext(nil, registry, eventName, event)
+}
+
+// DispatchContext works as Dispatch, but the event carries ctx, when ctx is nil the context
+// provided in the registry is used. The propagation stops with ctx.Err() once ctx is done.
+func (dispatcher *Dispatcher) DispatchContext(ctx context.Context, registry registry.Interface, eventName string, event Payload) (canceled bool, err error) {
+	if observers := dispatcher.findObservers(); len(observers) > 0 {
+		ctx = resolveContext(ctx, registry)
+		done := make([]func(canceled bool, err error), len(observers))
+		for i, observer := range observers {
+			ctx, done[i] = observer(ctx, eventName)
+		}
+		defer func() {
+			for i := len(done) - 1; i >= 0; i-- {
+				if done[i] != nil {
+					done[i](canceled, err)
+				}
+			}
+		}()
+	}
+
+	if outbox := dispatcher.findOutbox(); outbox != nil {
+		if codec := outbox.codec(eventName); codec != nil {
+			return outbox.dispatch(ctx, dispatcher, registry, eventName, event, codec)
+		}
+	}
+	event.init(ctx, registry, eventName)
 	return dispatcher.emit(eventName, event)
 }
@@ -22,7 +22,11 @@
 
 package event
 
-import "testing"
+import (
+	"context"
+	"github.com/CloudyKit/cloudy/registry"
+	"testing"
+)
 
 type TestContext struct {
 	Event
@@ -114,6 +118,78 @@ func TestEmitOrderANDCancellation(t *testing.T) {
 	}
 }
 
+func TestDispatchContextCancellation(t *testing.T) {
+	events := NewDispatcher()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	testcontext := new(TestContext)
+	events.Subscribe("cancelation", func(c *TestContext) {
+		c.Counter++
+	})
+	events.Subscribe("cancelation", func(c *TestContext) {
+		c.Counter++
+		cancel()
+	})
+
+	canceled, err := events.DispatchContext(ctx, nil, "cancelation", testcontext)
+	if !canceled || err != context.Canceled {
+		t.Fatalf("want canceled with context.Canceled got %v %v", canceled, err)
+	}
+	if testcontext.Counter != 1 {
+		t.Fatalf("handlers kept running after the context was canceled, counter %d", testcontext.Counter)
+	}
+}
+
+func TestDispatchContextFromRegistry(t *testing.T) {
+	events := NewDispatcher()
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	reg := registry.New()
+	defer reg.Dispose()
+	reg.WithTypeAndValue(goContextType, ctx)
+
+	var got context.Context
+	events.Subscribe("context", func(c *TestContext) {
+		got = c.Context()
+	})
+	events.Dispatch(reg, "context", new(TestContext))
+
+	if got != ctx {
+		t.Fatalf("event context was not loaded from the registry")
+	}
+}
+
+type observerKey struct{}
+
+func TestDispatcherObserve(t *testing.T) {
+	events := NewDispatcher()
+	child := events.Inherit()
+
+	var observed []string
+	events.Observe(func(ctx context.Context, eventName string) (context.Context, func(bool, error)) {
+		return context.WithValue(ctx, observerKey{}, eventName), func(canceled bool, err error) {
+			observed = append(observed, eventName)
+			if !canceled {
+				t.Errorf("observer: want canceled")
+			}
+		}
+	})
+
+	var value interface{}
+	child.Subscribe("observed", func(c *TestContext) {
+		value = c.Context().Value(observerKey{})
+		c.Cancel()
+	})
+	child.Dispatch(nil, "observed", new(TestContext))
+
+	if value != "observed" {
+		t.Fatalf("the event context was not returned by the observer, value %v", value)
+	}
+	if len(observed) != 1 || observed[0] != "observed" {
+		t.Fatalf("unexpected observed events %v", observed)
+	}
+}
+
 var bench_events = NewDispatcher()
 var bench_context = new(TestContext)
 
@@ -0,0 +1,311 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const segmentExt = ".seg"
+
+// DefaultSegmentSize is the size in bytes after which the journal starts a new segment file
+var DefaultSegmentSize int64 = 16 << 20
+
+// ErrCorruptRecord is wrapped by the errors of Outbox.Recover and Outbox.Replay when records of
+// the journal can't be read, the corrupt records are skipped and the other records are delivered
+var ErrCorruptRecord = errors.New("event.journal: corrupt record")
+
+// journalRecord is the unit stored in the journal, Data holds the gob encoded payload
+type journalRecord struct {
+	Offset uint64
+	Name   string
+	Time   time.Time
+	Data   []byte
+	Error  string
+}
+
+// journal is an append only log split in segment files, every segment is named
+// after the offset of its first record.
+type journal struct {
+	dir         string
+	segmentSize int64
+	segments    []uint64
+	file        *os.File
+	size        int64
+	next        uint64
+}
+
+func openJournal(dir string, segmentSize int64) (*journal, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	j := &journal{dir: dir, segmentSize: segmentSize, next: 1}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		j.segments = append(j.segments, first)
+	}
+	sort.Slice(j.segments, func(i, k int) bool { return j.segments[i] < j.segments[k] })
+
+	if len(j.segments) > 0 {
+		last := j.segments[len(j.segments)-1]
+		j.next = last
+		size, err := scanSegment(j.segmentPath(last), func(record *journalRecord) error {
+			j.next = record.Offset + 1
+			return nil
+		})
+		// corrupt records are skipped, they are reported when the journal is scanned
+		if err != nil && !errors.Is(err, ErrCorruptRecord) {
+			return nil, err
+		}
+		// drops any partially written record left by a crash
+		if err := os.Truncate(j.segmentPath(last), size); err != nil {
+			return nil, err
+		}
+		j.file, err = os.OpenFile(j.segmentPath(last), os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, err
+		}
+		j.size = size
+	}
+	return j, nil
+}
+
+func (j *journal) segmentPath(first uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", first, segmentExt))
+}
+
+// rotate closes the current segment and starts a new one at the next offset, the segment is
+// synced before closing as appends sync after the journal is unlocked, see syncRecord
+func (j *journal) rotate() (err error) {
+	if j.file != nil {
+		if err = j.file.Sync(); err != nil {
+			return
+		}
+		if err = j.file.Close(); err != nil {
+			return
+		}
+	}
+	j.file, err = os.OpenFile(j.segmentPath(j.next), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return
+	}
+	j.segments = append(j.segments, j.next)
+	j.size = 0
+	return
+}
+
+// append writes the record assigning it the next offset, the segment written is returned to be
+// synced with syncRecord once the journal is unlocked
+func (j *journal) append(record *journalRecord) (*os.File, error) {
+	if j.file == nil || j.size >= j.segmentSize {
+		if err := j.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	record.Offset = j.next
+	n, err := writeRecord(j.file, record)
+	if err != nil {
+		return nil, err
+	}
+	j.size += n
+	j.next++
+	return j.file, nil
+}
+
+// syncRecord syncs the segment returned by append, a segment closed in the meantime was synced
+// by rotate or Close
+func syncRecord(segment *os.File) error {
+	if err := segment.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// compact removes the segments holding only records before offset, the current segment is kept
+func (j *journal) compact(offset uint64) error {
+	for len(j.segments) > 1 && j.segments[1] <= offset {
+		if err := os.Remove(j.segmentPath(j.segments[0])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		j.segments = j.segments[1:]
+	}
+	return nil
+}
+
+// snapshot returns a copy of the segment list, safe to be scanned while the journal is appended
+func (j *journal) snapshot() []uint64 {
+	return append([]uint64(nil), j.segments...)
+}
+
+// scan walks every record in segments with offset greater or equal to from, segments removed by
+// compact are skipped, corrupt records are skipped and reported once the scan ends
+func (j *journal) scan(segments []uint64, from uint64, fn func(record *journalRecord) error) error {
+	var corrupt []error
+	for i, first := range segments {
+		if i+1 < len(segments) && segments[i+1] <= from {
+			continue
+		}
+		_, err := scanSegment(j.segmentPath(first), func(record *journalRecord) error {
+			if record.Offset < from {
+				return nil
+			}
+			return fn(record)
+		})
+		switch {
+		case errors.Is(err, ErrCorruptRecord):
+			corrupt = append(corrupt, err)
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		}
+	}
+	return errors.Join(corrupt...)
+}
+
+func (j *journal) Close() error {
+	if j.file != nil {
+		if err := j.file.Sync(); err != nil {
+			j.file.Close()
+			return err
+		}
+		return j.file.Close()
+	}
+	return nil
+}
+
+// writeRecord writes a record framed as: length, crc32 and the gob encoded record
+func writeRecord(w io.Writer, record *journalRecord) (int64, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 8))
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		return 0, err
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(b)-8))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(b[8:]))
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// readRecord reads one record, io.EOF is returned at the end of the segment and
+// io.ErrUnexpectedEOF when the tail was not completely written, ErrCorruptRecord is
+// returned with the size of a complete record which checksum or encoding is invalid
+func readRecord(r io.Reader, record *journalRecord) (int64, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(header[4:8]) {
+		return int64(len(b) + 8), ErrCorruptRecord
+	}
+	*record = journalRecord{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(record); err != nil {
+		return int64(len(b) + 8), ErrCorruptRecord
+	}
+	return int64(len(b) + 8), nil
+}
+
+// scanSegment calls fn for every valid record in the segment and returns the size of the valid part,
+// a corrupt record followed by other records is skipped and reported after the segment is scanned,
+// a corrupt or incomplete last record is the tail of a write interrupted by a crash and is ignored
+func scanSegment(name string, fn func(record *journalRecord) error) (size int64, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var corrupt []error
+	reader := bufio.NewReader(file)
+	for {
+		var record journalRecord
+		n, err := readRecord(reader, &record)
+		if err == ErrCorruptRecord {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return size, errors.Join(corrupt...)
+			}
+			corrupt = append(corrupt, fmt.Errorf("%w at byte %d of %s", ErrCorruptRecord, size, name))
+			size += n
+			continue
+		}
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return size, errors.Join(corrupt...)
+			}
+			return size, err
+		}
+		if err = fn(&record); err != nil {
+			return size, err
+		}
+		size += n
+	}
+}
+
+// appendFile appends a single record to a standalone file, used by the dead-letter file
+func appendFile(name string, record *journalRecord) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = writeRecord(file, record); err == nil {
+		err = file.Sync()
+	}
+	if cErr := file.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
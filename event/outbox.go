@@ -0,0 +1,374 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/CloudyKit/cloudy/registry"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	checkpointFile = "checkpoint"
+	deadLetterFile = "deadletter.log"
+)
+
+var (
+	eventType    = reflect.TypeOf(Event{})
+	eventPtrType = reflect.TypeOf((*Event)(nil))
+)
+
+// Outbox journals the payloads of marked events before they are delivered, events
+// are delivered at-least-once: an event is only checkpointed after all subscribers
+// ran, events left behind by a crash are delivered again by Recover. Marked events are delivered
+// without the cancellation of the dispatch context, once journaled a client going away must not
+// turn the event into a dead letter.
+//
+//	outbox, err := event.OpenOutbox(event.GetDispatcher(kernel.Registry), "./resources/outbox")
+//	outbox.Mark("order.created", (*OrderCreated)(nil))
+//	err = outbox.Recover()
+type Outbox struct {
+	dispatcher *Dispatcher
+	dir        string
+
+	mx         sync.Mutex
+	journal    *journal
+	checkpoint uint64
+	pending    map[uint64]struct{}
+
+	typesMx sync.RWMutex
+	types   map[string]*payloadCodec
+}
+
+// OpenOutbox opens or creates the journal in dir and attaches the outbox to the dispatcher,
+// the outbox is also used by dispatchers inheriting from dispatcher
+func OpenOutbox(dispatcher *Dispatcher, dir string) (*Outbox, error) {
+	if dispatcher == nil {
+		dispatcher = sub
+	}
+
+	journal, err := openJournal(dir, DefaultSegmentSize)
+	if err != nil {
+		return nil, err
+	}
+
+	outbox := &Outbox{
+		dispatcher: dispatcher,
+		dir:        dir,
+		journal:    journal,
+		pending:    make(map[uint64]struct{}),
+		types:      make(map[string]*payloadCodec),
+	}
+
+	if outbox.checkpoint, err = readCheckpoint(filepath.Join(dir, checkpointFile)); err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	dispatcher.outbox.Store(outbox)
+	return outbox, nil
+}
+
+// Mark enables the outbox for eventName, payload is a value of the type dispatched in the event,
+// usually a nil pointer ex: outbox.Mark("order.created", (*OrderCreated)(nil))
+func (outbox *Outbox) Mark(eventName string, payload Payload) {
+	codec := newPayloadCodec(reflect.TypeOf(payload))
+	outbox.typesMx.Lock()
+	outbox.types[eventName] = codec
+	outbox.typesMx.Unlock()
+}
+
+func (outbox *Outbox) codec(eventName string) *payloadCodec {
+	outbox.typesMx.RLock()
+	codec := outbox.types[eventName]
+	outbox.typesMx.RUnlock()
+	return codec
+}
+
+// Checkpoint returns the offset of the last event delivered in order
+func (outbox *Outbox) Checkpoint() uint64 {
+	outbox.mx.Lock()
+	defer outbox.mx.Unlock()
+	return outbox.checkpoint
+}
+
+// dispatch journals the event and then delivers the event using dispatcher
+func (outbox *Outbox) dispatch(ctx context.Context, dispatcher *Dispatcher, registry registry.Interface, eventName string, event Payload, codec *payloadCodec) (bool, error) {
+	data, err := codec.encode(event)
+	if err != nil {
+		return false, err
+	}
+
+	record := &journalRecord{Name: eventName, Time: time.Now(), Data: data}
+
+	outbox.mx.Lock()
+	segment, err := outbox.journal.append(record)
+	if err == nil {
+		outbox.pending[record.Offset] = struct{}{}
+	}
+	outbox.mx.Unlock()
+
+	// the sync runs unlocked, dispatches of other events append meanwhile, a record failing
+	// to sync stays pending and Recover delivers it again
+	if err == nil {
+		err = syncRecord(segment)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	event.init(context.WithoutCancel(resolveContext(ctx, registry)), registry, eventName)
+	canceled, err := dispatcher.emit(eventName, event)
+	if sErr := outbox.settle(record, err); sErr != nil && err == nil {
+		err = sErr
+	}
+	return canceled, err
+}
+
+// settle moves failed deliveries to the dead-letter file and advances the checkpoint
+func (outbox *Outbox) settle(record *journalRecord, deliveryErr error) error {
+	if deliveryErr != nil {
+		record.Error = deliveryErr.Error()
+		if err := appendFile(filepath.Join(outbox.dir, deadLetterFile), record); err != nil {
+			// keeps the event pending, Recover will try it again
+			return err
+		}
+	}
+
+	outbox.mx.Lock()
+	defer outbox.mx.Unlock()
+
+	delete(outbox.pending, record.Offset)
+	checkpoint := outbox.journal.next - 1
+	for offset := range outbox.pending {
+		if offset-1 < checkpoint {
+			checkpoint = offset - 1
+		}
+	}
+
+	if checkpoint != outbox.checkpoint {
+		outbox.checkpoint = checkpoint
+		return writeCheckpoint(filepath.Join(outbox.dir, checkpointFile), checkpoint)
+	}
+	return nil
+}
+
+// Recover delivers again the events journaled after the checkpoint, call Recover once
+// all event types are marked and before new events are dispatched. Corrupt records are
+// skipped, the error wraps ErrCorruptRecord and is returned after the other events are delivered.
+func (outbox *Outbox) Recover() error {
+	var records []*journalRecord
+
+	outbox.mx.Lock()
+	err := outbox.journal.scan(outbox.journal.snapshot(), outbox.checkpoint+1, func(record *journalRecord) error {
+		if _, isPending := outbox.pending[record.Offset]; !isPending {
+			outbox.pending[record.Offset] = struct{}{}
+			records = append(records, record)
+		}
+		return nil
+	})
+	outbox.mx.Unlock()
+
+	// corrupt records are skipped, the error is returned once the other records are delivered
+	corrupt := err
+	if err != nil && !errors.Is(err, ErrCorruptRecord) {
+		return err
+	}
+
+	for _, record := range records {
+		var deliveryErr error
+		if codec := outbox.codec(record.Name); codec == nil {
+			deliveryErr = fmt.Errorf("event.Outbox: event %q is not marked", record.Name)
+		} else if event, err := codec.decode(record.Data); err != nil {
+			deliveryErr = err
+		} else {
+			event.init(nil, nil, record.Name)
+			_, deliveryErr = outbox.dispatcher.emit(record.Name, event)
+		}
+		if err := outbox.settle(record, deliveryErr); err != nil {
+			return err
+		}
+	}
+	return corrupt
+}
+
+// Replay dispatches again every journaled event starting at offset from, replays don't move
+// the checkpoint and are meant to rebuild projections, events no longer marked are skipped.
+// Events removed by Compact are not replayed, corrupt records are skipped as in Recover.
+func (outbox *Outbox) Replay(from uint64) error {
+	outbox.mx.Lock()
+	segments := outbox.journal.snapshot()
+	outbox.mx.Unlock()
+
+	return outbox.journal.scan(segments, from, func(record *journalRecord) error {
+		codec := outbox.codec(record.Name)
+		if codec == nil {
+			return nil
+		}
+		event, err := codec.decode(record.Data)
+		if err != nil {
+			return err
+		}
+		event.init(nil, nil, record.Name)
+		if _, err = outbox.dispatcher.emit(record.Name, event); err != nil {
+			return fmt.Errorf("event.Outbox: replaying offset %d: %w", record.Offset, err)
+		}
+		return nil
+	})
+}
+
+// Compact removes the segments of the journal holding only events delivered up to the checkpoint,
+// the segment being appended is kept. Compact limits the size of the journal, events removed can't
+// be replayed, ex: compact periodically when the projections don't need a full Replay.
+func (outbox *Outbox) Compact() error {
+	outbox.mx.Lock()
+	defer outbox.mx.Unlock()
+	return outbox.journal.compact(outbox.checkpoint + 1)
+}
+
+// DeadLetter is an event which delivery failed
+type DeadLetter struct {
+	Offset    uint64
+	EventName string
+	Time      time.Time
+	Payload   Payload // Payload is nil when the event is no longer marked
+	Reason    string
+}
+
+// DeadLetters walks the dead-letter file
+func (outbox *Outbox) DeadLetters(fn func(letter DeadLetter) error) error {
+	_, err := scanSegment(filepath.Join(outbox.dir, deadLetterFile), func(record *journalRecord) error {
+		letter := DeadLetter{Offset: record.Offset, EventName: record.Name, Time: record.Time, Reason: record.Error}
+		if codec := outbox.codec(record.Name); codec != nil {
+			letter.Payload, _ = codec.decode(record.Data)
+		}
+		return fn(letter)
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// Close detaches the outbox and closes the journal
+func (outbox *Outbox) Close() error {
+	outbox.dispatcher.outbox.CompareAndSwap(outbox, nil)
+	outbox.mx.Lock()
+	defer outbox.mx.Unlock()
+	return outbox.journal.Close()
+}
+
+func readCheckpoint(name string) (uint64, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func writeCheckpoint(name string, checkpoint uint64) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(checkpoint, 10)), 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// payloadCodec gob encodes the exported fields of a payload, the embedded Event is left out
+// as it holds only dispatch state and is allocated again when decoded.
+type payloadCodec struct {
+	typ    reflect.Type
+	fields []int
+	shadow reflect.Type
+}
+
+func newPayloadCodec(typ reflect.Type) *payloadCodec {
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("event.Outbox: payload type %v is not a pointer to struct", typ))
+	}
+
+	codec := &payloadCodec{typ: typ.Elem()}
+	var fields []reflect.StructField
+	for i := 0; i < codec.typ.NumField(); i++ {
+		field := codec.typ.Field(i)
+		if field.PkgPath != "" || field.Type == eventType || field.Type == eventPtrType {
+			continue
+		}
+		codec.fields = append(codec.fields, i)
+		fields = append(fields, reflect.StructField{Name: field.Name, Type: field.Type, Tag: field.Tag})
+	}
+	codec.shadow = reflect.StructOf(fields)
+	return codec
+}
+
+func (codec *payloadCodec) encode(event Payload) ([]byte, error) {
+	value := reflect.ValueOf(event).Elem()
+	if value.Type() != codec.typ {
+		return nil, fmt.Errorf("event.Outbox: unexpected payload type %v, marked type is %v", value.Type(), codec.typ)
+	}
+
+	if len(codec.fields) == 0 {
+		return nil, nil
+	}
+
+	shadow := reflect.New(codec.shadow).Elem()
+	for i, field := range codec.fields {
+		shadow.Field(i).Set(value.Field(field))
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).EncodeValue(shadow); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (codec *payloadCodec) decode(data []byte) (Payload, error) {
+	if len(codec.fields) == 0 {
+		return newPayload(codec.typ).Interface().(Payload), nil
+	}
+
+	shadow := reflect.New(codec.shadow)
+	if err := gob.NewDecoder(bytes.NewReader(data)).DecodeValue(shadow); err != nil {
+		return nil, err
+	}
+
+	value := newPayload(codec.typ)
+	for i, field := range codec.fields {
+		value.Elem().Field(field).Set(shadow.Elem().Field(i))
+	}
+	return value.Interface().(Payload), nil
+}
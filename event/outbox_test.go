@@ -0,0 +1,298 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type orderCreated struct {
+	Event
+	OrderID int
+	Total   float64
+}
+
+func TestOutbox_DispatchAndReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	events := NewDispatcher()
+	outbox, err := OpenOutbox(events, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.Mark("order.created", (*orderCreated)(nil))
+
+	var received []int
+	events.Subscribe("order.created", func(e *orderCreated) {
+		received = append(received, e.OrderID)
+	})
+
+	for i := 1; i <= 3; i++ {
+		if _, err := events.Inherit().Dispatch(nil, "order.created", &orderCreated{OrderID: i, Total: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if outbox.Checkpoint() != 3 {
+		t.Fatalf("want checkpoint 3 got %d", outbox.Checkpoint())
+	}
+
+	received = nil
+	if err := outbox.Replay(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[0] != 2 || received[1] != 3 {
+		t.Fatalf("unexpected replayed events %v", received)
+	}
+	outbox.Close()
+}
+
+func TestOutbox_RecoverAndDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+
+	events := NewDispatcher()
+	outbox, err := OpenOutbox(events, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.Mark("order.created", (*orderCreated)(nil))
+
+	// simulates a crash in the middle of the delivery: the journal is written but never checkpointed
+	outbox.mx.Lock()
+	outbox.journal.append(&journalRecord{Name: "order.created", Data: mustEncode(t, outbox, &orderCreated{OrderID: 7})})
+	outbox.mx.Unlock()
+	outbox.Close()
+
+	events = NewDispatcher()
+	outbox, err = OpenOutbox(events, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	outbox.Mark("order.created", (*orderCreated)(nil))
+
+	var recovered int
+	events.Subscribe("order.created", func(e *orderCreated) {
+		recovered = e.OrderID
+		if e.OrderID == 8 {
+			e.CancelWithError(errors.New("payment refused"))
+		}
+	})
+
+	if err := outbox.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	if recovered != 7 || outbox.Checkpoint() != 1 {
+		t.Fatalf("event was not recovered: got order %d checkpoint %d", recovered, outbox.Checkpoint())
+	}
+
+	if _, err := events.Dispatch(nil, "order.created", &orderCreated{OrderID: 8}); err == nil {
+		t.Fatal("expected the subscriber error")
+	}
+
+	var letters []DeadLetter
+	outbox.DeadLetters(func(letter DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	})
+	if len(letters) != 1 || letters[0].Reason != "payment refused" || letters[0].Payload.(*orderCreated).OrderID != 8 {
+		t.Fatalf("unexpected dead letters %#v", letters)
+	}
+	if outbox.Checkpoint() != 2 {
+		t.Fatalf("want checkpoint 2 got %d", outbox.Checkpoint())
+	}
+}
+
+func TestOutbox_ReplayEmbeddedEventPointer(t *testing.T) {
+	events := NewDispatcher()
+	outbox, err := OpenOutbox(events, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	outbox.Mark("user.renamed", (*ValueEvent)(nil))
+
+	var received []interface{}
+	events.Subscribe("user.renamed", func(e *ValueEvent) {
+		received = append(received, e.Value)
+	})
+
+	if _, err := events.Dispatch(nil, "user.renamed", &ValueEvent{Event: &Event{}, Value: "ana"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Replay(1); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[1] != "ana" {
+		t.Fatalf("unexpected replayed events %v", received)
+	}
+}
+
+func TestOutbox_CanceledContext(t *testing.T) {
+	events := NewDispatcher()
+	outbox, err := OpenOutbox(events, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	outbox.Mark("order.created", (*orderCreated)(nil))
+
+	var delivered int
+	events.Subscribe("order.created", func(e *orderCreated) {
+		if err := e.Context().Err(); err != nil {
+			e.CancelWithError(err)
+			return
+		}
+		delivered++
+	})
+	events.Subscribe("order.created", func(e *orderCreated) {
+		delivered++
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := events.DispatchContext(ctx, nil, "order.created", &orderCreated{OrderID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 2 || outbox.Checkpoint() != 1 {
+		t.Fatalf("the event was not delivered: %d deliveries checkpoint %d", delivered, outbox.Checkpoint())
+	}
+	outbox.DeadLetters(func(letter DeadLetter) error {
+		t.Errorf("unexpected dead letter %#v", letter)
+		return nil
+	})
+}
+
+func mustEncode(t *testing.T, outbox *Outbox, event Payload) []byte {
+	data, err := outbox.codec("order.created").encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestOutbox_CorruptRecord(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	events := NewDispatcher()
+	outbox, err := OpenOutbox(events, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.Mark("order.created", (*orderCreated)(nil))
+	for i := 1; i <= 3; i++ {
+		if _, err := events.Dispatch(nil, "order.created", &orderCreated{OrderID: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segment := outbox.journal.segmentPath(outbox.journal.segments[0])
+	outbox.Close()
+
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0750 {
+		t.Fatalf("unexpected journal directory permissions %v %v", info.Mode(), err)
+	}
+
+	// flips a byte of the second record
+	b, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := 8 + int(binary.BigEndian.Uint32(b[0:4]))
+	b[second+8+4] ^= 0xff
+	if err := os.WriteFile(segment, b, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	events = NewDispatcher()
+	outbox, err = OpenOutbox(events, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	outbox.Mark("order.created", (*orderCreated)(nil))
+
+	var received []int
+	events.Subscribe("order.created", func(e *orderCreated) {
+		received = append(received, e.OrderID)
+	})
+	if err := outbox.Replay(1); !errors.Is(err, ErrCorruptRecord) {
+		t.Fatalf("expected ErrCorruptRecord got %v", err)
+	}
+	if len(received) != 2 || received[0] != 1 || received[1] != 3 {
+		t.Fatalf("only the corrupt record should be skipped, got %v", received)
+	}
+
+	// the journal keeps appending after the last record
+	if _, err := events.Dispatch(nil, "order.created", &orderCreated{OrderID: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if outbox.Checkpoint() != 4 {
+		t.Fatalf("want checkpoint 4 got %d", outbox.Checkpoint())
+	}
+}
+
+func TestOutbox_Compact(t *testing.T) {
+	segmentSize := DefaultSegmentSize
+	DefaultSegmentSize = 1
+	defer func() { DefaultSegmentSize = segmentSize }()
+
+	dir := t.TempDir()
+	events := NewDispatcher()
+	outbox, err := OpenOutbox(events, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	outbox.Mark("order.created", (*orderCreated)(nil))
+
+	var received []int
+	events.Subscribe("order.created", func(e *orderCreated) {
+		received = append(received, e.OrderID)
+	})
+	for i := 1; i <= 3; i++ {
+		if _, err := events.Dispatch(nil, "order.created", &orderCreated{OrderID: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := outbox.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(segments) != 1 {
+		t.Fatalf("want the current segment got %v", segments)
+	}
+
+	received = nil
+	if err := outbox.Replay(1); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != 3 {
+		t.Fatalf("unexpected replayed events %v", received)
+	}
+}
@@ -0,0 +1,283 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+)
+
+var errTransportClosed = errors.New("event.Transport: transport is closed")
+
+// MemoryBroker connects in-memory transports, messages published in one transport are
+// delivered synchronously to the handlers of every other transport of the broker
+type MemoryBroker struct {
+	mx    sync.RWMutex
+	peers map[*memoryTransport]struct{}
+}
+
+// NewMemoryBroker creates a new in-memory broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{peers: make(map[*memoryTransport]struct{})}
+}
+
+// Transport creates a new transport connected to the broker
+func (broker *MemoryBroker) Transport() Transport {
+	peer := &memoryTransport{broker: broker}
+	broker.mx.Lock()
+	broker.peers[peer] = struct{}{}
+	broker.mx.Unlock()
+	return peer
+}
+
+type memoryTransport struct {
+	broker   *MemoryBroker
+	mx       sync.RWMutex
+	handlers []func(message Message)
+}
+
+func (transport *memoryTransport) Publish(message Message) error {
+	broker := transport.broker
+	broker.mx.RLock()
+	if _, connected := broker.peers[transport]; !connected {
+		broker.mx.RUnlock()
+		return errTransportClosed
+	}
+	peers := make([]*memoryTransport, 0, len(broker.peers))
+	for peer := range broker.peers {
+		if peer != transport {
+			peers = append(peers, peer)
+		}
+	}
+	broker.mx.RUnlock()
+
+	for _, peer := range peers {
+		peer.deliver(message)
+	}
+	return nil
+}
+
+func (transport *memoryTransport) deliver(message Message) {
+	transport.mx.RLock()
+	handlers := transport.handlers
+	transport.mx.RUnlock()
+	for _, handler := range handlers {
+		handler(message)
+	}
+}
+
+func (transport *memoryTransport) Subscribe(handler func(message Message)) error {
+	transport.mx.Lock()
+	transport.handlers = append(transport.handlers, handler)
+	transport.mx.Unlock()
+	return nil
+}
+
+func (transport *memoryTransport) Close() error {
+	transport.broker.mx.Lock()
+	delete(transport.broker.peers, transport)
+	transport.broker.mx.Unlock()
+	return nil
+}
+
+// TCPTransport exchanges messages as line-delimited json over tcp connections, a listening
+// transport relays the messages received from one connection to all the other connections,
+// acting as the broker for the transports connected with DialTCP.
+type TCPTransport struct {
+	listener net.Listener
+
+	mx       sync.RWMutex
+	conns    map[*tcpConn]struct{}
+	handlers []func(message Message)
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type tcpConn struct {
+	net.Conn
+	mx      sync.Mutex
+	encoder *json.Encoder
+}
+
+func (conn *tcpConn) send(message Message) error {
+	conn.mx.Lock()
+	defer conn.mx.Unlock()
+	// json.Encoder terminates every value with a new line
+	return conn.encoder.Encode(message)
+}
+
+// ListenTCP creates a transport accepting connections on address
+func ListenTCP(address string) (*TCPTransport, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &TCPTransport{listener: listener, conns: make(map[*tcpConn]struct{})}
+	transport.wg.Add(1)
+	go transport.accept()
+	return transport, nil
+}
+
+// DialTCP creates a transport connected to a transport created with ListenTCP
+func DialTCP(address string) (*TCPTransport, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &TCPTransport{conns: make(map[*tcpConn]struct{})}
+	transport.add(conn)
+	return transport, nil
+}
+
+// Addr returns the listening address, nil for dialed transports
+func (transport *TCPTransport) Addr() net.Addr {
+	if transport.listener == nil {
+		return nil
+	}
+	return transport.listener.Addr()
+}
+
+func (transport *TCPTransport) accept() {
+	defer transport.wg.Done()
+	for {
+		conn, err := transport.listener.Accept()
+		if err != nil {
+			return
+		}
+		transport.add(conn)
+	}
+}
+
+func (transport *TCPTransport) add(conn net.Conn) {
+	c := &tcpConn{Conn: conn, encoder: json.NewEncoder(conn)}
+
+	transport.mx.Lock()
+	if transport.closed {
+		transport.mx.Unlock()
+		conn.Close()
+		return
+	}
+	transport.conns[c] = struct{}{}
+	transport.wg.Add(1)
+	transport.mx.Unlock()
+
+	go transport.read(c)
+}
+
+func (transport *TCPTransport) read(conn *tcpConn) {
+	defer transport.wg.Done()
+	defer transport.remove(conn)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var message Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+
+		if transport.listener != nil {
+			transport.broadcast(message, conn)
+		}
+
+		transport.mx.RLock()
+		handlers := transport.handlers
+		transport.mx.RUnlock()
+		for _, handler := range handlers {
+			handler(message)
+		}
+	}
+}
+
+func (transport *TCPTransport) remove(conn *tcpConn) {
+	transport.mx.Lock()
+	delete(transport.conns, conn)
+	transport.mx.Unlock()
+	conn.Close()
+}
+
+func (transport *TCPTransport) broadcast(message Message, skip *tcpConn) (err error) {
+	transport.mx.RLock()
+	conns := make([]*tcpConn, 0, len(transport.conns))
+	for conn := range transport.conns {
+		if conn != skip {
+			conns = append(conns, conn)
+		}
+	}
+	transport.mx.RUnlock()
+
+	for _, conn := range conns {
+		if sErr := conn.send(message); sErr != nil && err == nil {
+			err = sErr
+		}
+	}
+	return
+}
+
+// Publish sends the message to every connection
+func (transport *TCPTransport) Publish(message Message) error {
+	transport.mx.RLock()
+	closed := transport.closed
+	transport.mx.RUnlock()
+	if closed {
+		return errTransportClosed
+	}
+	return transport.broadcast(message, nil)
+}
+
+// Subscribe registers handler to be called for every message received
+func (transport *TCPTransport) Subscribe(handler func(message Message)) error {
+	transport.mx.Lock()
+	defer transport.mx.Unlock()
+	if transport.closed {
+		return errTransportClosed
+	}
+	transport.handlers = append(transport.handlers, handler)
+	return nil
+}
+
+// Close closes the listener and all connections, waiting the reading goroutines to finish
+func (transport *TCPTransport) Close() error {
+	transport.mx.Lock()
+	if transport.closed {
+		transport.mx.Unlock()
+		return nil
+	}
+	transport.closed = true
+	var err error
+	if transport.listener != nil {
+		err = transport.listener.Close()
+	}
+	for conn := range transport.conns {
+		conn.Close()
+	}
+	transport.mx.Unlock()
+
+	transport.wg.Wait()
+	return err
+}
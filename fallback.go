@@ -0,0 +1,116 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"github.com/CloudyKit/router"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// NotFound sets the handler for requests not matching any route, the handler runs after the
+// middlewares of the kernel with a request registry, as any other route
+func (kernel *Kernel) NotFound(handler Handler) {
+	kernel.notFound = handler
+}
+
+// MethodNotAllowed sets the handler for requests matching a route of other methods, the Allow
+// header is set before the handler runs, the handler runs after the middlewares of the kernel
+func (kernel *Kernel) MethodNotAllowed(handler Handler) {
+	kernel.methodNotAllowed = handler
+}
+
+// serveFallback handles requests not matching a route for the request method:
+// HEAD requests run the GET route, OPTIONS requests are answered with the Allow header,
+// other methods of a matched path run the MethodNotAllowed handler, otherwise NotFound runs
+func (kernel *Kernel) serveFallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		if handler, params := kernel.Router.FindRoute(http.MethodGet, r.URL.Path); handler != nil {
+			handler(w, r, params)
+			return
+		}
+	}
+
+	allowed := kernel.allowedMethods(r.URL.Path)
+	if len(allowed) == 0 {
+		kernel.dispatchFallback("NotFound", w, r, kernel.notFound, defaultNotFound)
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	if r.Method == http.MethodOptions {
+		kernel.dispatchFallback("Options", w, r, nil, defaultOptions)
+		return
+	}
+	kernel.dispatchFallback("MethodNotAllowed", w, r, kernel.methodNotAllowed, defaultMethodNotAllowed)
+}
+
+func (kernel *Kernel) dispatchFallback(name string, w http.ResponseWriter, r *http.Request, handler, defaultHandler Handler) {
+	if handler == nil {
+		handler = defaultHandler
+	}
+	c := newRequestContext()
+	defer requestRecover(c)
+	c.route = kernel.Registry
+	_ = DispatchNext(c, name, w, r, router.Parameter{}, kernel.Registry.Fork(), kernel.reSlice(handler))
+}
+
+// allowedMethods returns the methods with a route matching path, the router is queried with
+// the method set of the route table
+func (kernel *Kernel) allowedMethods(path string) []string {
+	var allowed []string
+	for _, method := range kernel.routes.methodSet() {
+		if handler, _ := kernel.Router.FindRoute(method, path); handler != nil {
+			allowed = append(allowed, method)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	hasMethod := func(method string) bool {
+		index := sort.SearchStrings(allowed, method)
+		return index < len(allowed) && allowed[index] == method
+	}
+	if hasMethod(http.MethodGet) && !hasMethod(http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	if !hasMethod(http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+var (
+	defaultNotFound = HandlerFunc(func(c *Context) {
+		http.NotFound(c.Response, c.Request)
+	})
+	defaultMethodNotAllowed = HandlerFunc(func(c *Context) {
+		http.Error(c.Response, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
+	defaultOptions = HandlerFunc(func(c *Context) {
+		c.Response.WriteHeader(http.StatusNoContent)
+	})
+)
@@ -0,0 +1,95 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKernel_Fallback(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddMiddleware(middlewareTrace("kernel"))
+	kernel.AddHandlerFunc("GET|POST", "/users/:id", func(c *Context) {
+		c.WriteString("user " + c.GetURLParameter("id"))
+	})
+	kernel.NotFound(HandlerFunc(func(c *Context) {
+		c.Response.WriteHeader(404)
+		c.WriteString("custom not found " + c.Name)
+	}))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder
+	}
+
+	recorder := serve("GET", "/missing")
+	if recorder.Code != 404 || recorder.Body.String() != "custom not found NotFound" || recorder.Header().Get("X-Trace") != "kernel" {
+		t.Fatalf("not found: unexpected response %d %q %v", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+
+	recorder = serve("DELETE", "/users/5")
+	if recorder.Code != 405 || recorder.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" || recorder.Header().Get("X-Trace") != "kernel" {
+		t.Fatalf("method not allowed: unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+
+	kernel.MethodNotAllowed(HandlerFunc(func(c *Context) {
+		c.Response.WriteHeader(405)
+		c.WriteString("custom method not allowed")
+	}))
+	if recorder = serve("PUT", "/users/5"); recorder.Body.String() != "custom method not allowed" {
+		t.Fatalf("method not allowed: unexpected body %q", recorder.Body.String())
+	}
+
+	recorder = serve("OPTIONS", "/users/5")
+	if recorder.Code != 204 || recorder.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		t.Fatalf("options: unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+
+	recorder = serve("HEAD", "/users/5")
+	if recorder.Code != 200 || recorder.Header().Get("X-Trace") != "kernel" {
+		t.Fatalf("head: unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestKernel_AllowedMethods(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("GET", "/files/:name", func(c *Context) {})
+	kernel.Group("/dav", func(group *Kernel) {
+		group.AddHandlerFunc("PROPFIND", "/files/:name", func(c *Context) {})
+	})
+
+	if allowed := kernel.allowedMethods("/dav/files/a"); len(allowed) != 2 || allowed[0] != "OPTIONS" || allowed[1] != "PROPFIND" {
+		t.Errorf("unexpected methods %v", allowed)
+	}
+	if allowed := kernel.allowedMethods("/files/a"); len(allowed) != 3 || allowed[0] != "GET" || allowed[1] != "HEAD" {
+		t.Errorf("unexpected methods %v", allowed)
+	}
+	if allowed := kernel.allowedMethods("/missing"); allowed != nil {
+		t.Errorf("unexpected methods %v", allowed)
+	}
+	if allowed := NewKernel().allowedMethods("/"); allowed != nil {
+		t.Errorf("unexpected methods %v", allowed)
+	}
+}
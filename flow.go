@@ -23,6 +23,7 @@
 package cloudy
 
 import (
+	"github.com/CloudyKit/cloudy/link"
 	"github.com/CloudyKit/router"
 	"net/http"
 )
@@ -45,18 +46,45 @@ type Handler interface {
 // DispatchNext entry point
 func DispatchNext(context *Context, name string, writer http.ResponseWriter, request *http.Request, parameter router.Parameter, registry Registry, handlers []Handler) error {
 	context.Name = name
-	context.Response = writer
+	context.writer.reset(writer)
+	context.response = context.writer.wrap()
+	context.Response = context.response
 	context.Request = request
 	context.Params = parameter
 	context.Registry = registry
 	context.handlers = handlers
+	if request != nil {
+		context.HostParams, _ = request.Context().Value(hostParamsKey{}).(HostParams)
+		// urls generated in a mounted kernel are relative to the mount, see Kernel.Mount
+		if mountPath, _ := request.Context().Value(mountPathKey{}).(string); mountPath != "" {
+			registry.WithTypeAndValue(link.URLGenType, &mountURLGen{urlGen: link.GetURLGen(registry), mountPath: mountPath})
+		}
+	}
 	if context.Request.Body != nil {
 		context.body = context.Request.Body
-		context.Request.Body = context.GetBodyReader()
+		context.Request.Body = requestBody{context}
 	}
 
 	//maps the request context into the scoped variables
 	registry.WithValues(context)
+	// provides the go context of the request, ex: events dispatched in this request carry this context
+	registry.WithTypeAndProviderFunc(GoContextType, func(c Registry) interface{} {
+		return GetContext(c).GoContext()
+	})
+	// provides the request, ex: link.RequestBaseURL derives absolute urls from the request
+	registry.WithTypeAndProviderFunc(RequestType, func(c Registry) interface{} {
+		return GetContext(c).Request
+	})
+
+	// provides the request logger, derived from the logger of the registry where the route was added
+	logger := GetSlog(registry)
+	registry.WithTypeAndProviderFunc(SlogType, func(c Registry) interface{} {
+		return requestSlog(GetContext(c), logger)
+	})
+	// provides the values captured by a wildcard host, see Kernel.Host
+	registry.WithTypeAndProviderFunc(HostParamsType, func(c Registry) interface{} {
+		return GetContext(c).HostParams
+	})
 
 	return context.Next()
 }
@@ -6,3 +6,5 @@ require (
 	github.com/CloudyKit/router v1.0.1
 	github.com/pkg/errors v0.9.1
 )
+
+require github.com/julienschmidt/httprouter v1.3.0 // indirect
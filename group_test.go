@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type groupUsers struct {
+	Context *Context
+}
+
+func (users *groupUsers) Mx(mx *Mapper) {
+	mx.Name = "Users"
+	mx.BindAction("GET", "/users/:id", "Show")
+}
+
+func (users *groupUsers) Show() {
+	users.Context.WriteString("user " + users.Context.GetURLParameter("id"))
+}
+
+func middlewareTrace(name string) HandlerFunc {
+	return func(c *Context) {
+		c.Response.Header().Add("X-Trace", name)
+		c.Next()
+	}
+}
+
+func TestKernel_Group(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddMiddleware(middlewareTrace("kernel"))
+
+	kernel.Group("/api", func(api *Kernel) {
+		api.NamePrefix = "api."
+		api.Group("/admin", func(admin *Kernel) {
+			admin.NamePrefix = "admin."
+			admin.AddControllers(&groupUsers{})
+		}, middlewareTrace("admin"))
+		api.AddHandlerFunc("GET", "/ping", func(c *Context) {
+			c.WriteString("pong")
+		})
+	}, middlewareTrace("api"))
+
+	kernel.AddHandlerFunc("GET", "/ping", func(c *Context) {
+		c.WriteString("root pong")
+	})
+
+	recorder := httptest.NewRecorder()
+	kernel.Router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/admin/users/5", nil))
+	if body := recorder.Body.String(); body != "user 5" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if trace := recorder.Header()["X-Trace"]; len(trace) != 3 || trace[0] != "kernel" || trace[1] != "api" || trace[2] != "admin" {
+		t.Fatalf("unexpected middleware chain %v", trace)
+	}
+
+	recorder = httptest.NewRecorder()
+	kernel.Router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	if trace := recorder.Header()["X-Trace"]; len(trace) != 1 || recorder.Body.String() != "root pong" {
+		t.Fatalf("group middlewares leaked to the kernel: %v %q", trace, recorder.Body.String())
+	}
+
+	name := "api.admin.Users.Show"
+	if url := kernel.URLGen.URL(name, 5); url != "/api/admin/users/5" {
+		t.Fatalf("unexpected url %q", url)
+	}
+}
+
+type groupDisposer struct {
+	disposed *bool
+}
+
+func (d groupDisposer) Dispose() {
+	*d.disposed = true
+}
+
+func TestKernel_GroupDispose(t *testing.T) {
+	kernel := NewKernel()
+	disposed := false
+	kernel.Group("/api", func(api *Kernel) {
+		api.Group("/admin", func(admin *Kernel) {
+			admin.Registry.WithValues(groupDisposer{&disposed})
+			admin.AddHandlerFunc("GET", "/users", func(c *Context) {})
+		})
+	})
+
+	kernel.MustDispose()
+	if !disposed {
+		t.Fatal("the registry of the nested group should be disposed with the kernel")
+	}
+}
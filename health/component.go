@@ -0,0 +1,123 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/CloudyKit/cloudy"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Component serves the liveness report in LivenessPath and the readiness report in ReadinessPath,
+// the checks are contributed by the components or by the app with AddChecker and run concurrently,
+// a failing check answers with 503.
+//
+//	kernel.AddComponents(&health.Component{}, &session.Component{Manager: manager})
+//	health.AddChecker(kernel.Registry, "session", manager.StoreChecker(kernel.Registry))
+//	// before shutting down
+//	health.SetDraining(kernel.Registry)
+type Component struct {
+	LivenessPath  string        // LivenessPath default /healthz
+	ReadinessPath string        // ReadinessPath default /readyz
+	Timeout       time.Duration // Timeout of each check, default 5s
+	CacheTTL      time.Duration // CacheTTL is how long results are reused, default 1s, negative disables the cache
+}
+
+// Report is the body of the health endpoints
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	if component.LivenessPath == "" {
+		component.LivenessPath = "/healthz"
+	}
+	if component.ReadinessPath == "" {
+		component.ReadinessPath = "/readyz"
+	}
+	if component.Timeout == 0 {
+		component.Timeout = 5 * time.Second
+	}
+	if component.CacheTTL == 0 {
+		component.CacheTTL = time.Second
+	}
+
+	// the checks are kept in the root registry, components bootstrapped later add to the same set
+	load(kernel.Registry)
+
+	kernel.AddHandlerName("health.liveness", "GET|HEAD", component.LivenessPath, cloudy.HandlerFunc(func(c *cloudy.Context) {
+		component.serve(c, true)
+	}))
+	kernel.AddHandlerName("health.readiness", "GET|HEAD", component.ReadinessPath, cloudy.HandlerFunc(func(c *cloudy.Context) {
+		component.serve(c, false)
+	}))
+}
+
+func (component *Component) serve(c *cloudy.Context, liveness bool) {
+	checks := lookup(c.Registry)
+	report := component.run(c.Request.Context(), checks.snapshot(liveness))
+	if !liveness && checks.draining.Load() {
+		report.Status = StatusDraining
+	}
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	header := c.Response.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	c.Response.WriteHeader(status)
+	if c.Request.Method != http.MethodHead {
+		_ = json.NewEncoder(c.Response).Encode(report)
+	}
+}
+
+// run runs list concurrently, the report fails when one of the checks fails
+func (component *Component) run(ctx context.Context, list []*check) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(list))}
+	results := make([]Result, len(list))
+
+	var wg sync.WaitGroup
+	for i := range list {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = list[i].run(ctx, component.Timeout, component.CacheTTL)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		report.Checks[list[i].Name] = result
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
@@ -0,0 +1,242 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package health serves liveness and readiness endpoints from the checks contributed to the
+// registry by the components, see Component.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/registry"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var checksType = reflect.TypeOf((*checkSet)(nil))
+
+// Checker checks a dependency, a nil error means healthy, the ctx is canceled when the check times out
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a func to a Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (fn CheckerFunc) Check(ctx context.Context) error {
+	return fn(ctx)
+}
+
+// Check describes a named checker, zero Timeout and CacheTTL use the values of the Component
+type Check struct {
+	Name     string
+	Checker  Checker
+	Timeout  time.Duration // Timeout of a single run
+	CacheTTL time.Duration // CacheTTL is how long a result is reused, negative disables the cache
+	Liveness bool          // Liveness checks are part of the liveness and readiness reports, others only of readiness
+}
+
+// ErrTimeout is the error of a check that did not finish in time
+var ErrTimeout = errors.New("health: check timed out")
+
+// Result is the outcome of a check
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached,omitempty"`
+}
+
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+type check struct {
+	Check
+
+	mx        sync.Mutex
+	result    Result
+	expiresAt time.Time
+	running   chan struct{} // running is closed when the run in progress finishes
+}
+
+// run returns the cached result when valid, otherwise runs the checker bounded by its timeout,
+// a report asking while the checker runs waits for the same run, the lock is not held meanwhile
+func (check *check) run(ctx context.Context, timeout, ttl time.Duration) Result {
+	if check.Timeout > 0 {
+		timeout = check.Timeout
+	}
+	if check.CacheTTL != 0 {
+		ttl = check.CacheTTL
+	}
+
+	check.mx.Lock()
+	if ttl > 0 && time.Now().Before(check.expiresAt) {
+		result := check.result
+		check.mx.Unlock()
+		result.Cached = true
+		return result
+	}
+	if running := check.running; running != nil {
+		check.mx.Unlock()
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return Result{Status: StatusFail, Error: ctx.Err().Error(), Duration: "0s", CheckedAt: time.Now()}
+		}
+		check.mx.Lock()
+		defer check.mx.Unlock()
+		return check.result
+	}
+	running := make(chan struct{})
+	check.running = running
+	check.mx.Unlock()
+
+	start := time.Now()
+	err := runChecker(ctx, check.Checker, timeout)
+	result := Result{Status: StatusOK, Duration: time.Since(start).String(), CheckedAt: start}
+	if err != nil {
+		result.Status, result.Error = StatusFail, err.Error()
+	}
+
+	check.mx.Lock()
+	check.result, check.expiresAt, check.running = result, start.Add(ttl), nil
+	check.mx.Unlock()
+	close(running)
+	return result
+}
+
+// runChecker runs checker in its own goroutine, so checkers ignoring ctx still honour the timeout
+func runChecker(ctx context.Context, checker Checker, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("health: check panicked: %v", r)
+			}
+		}()
+		done <- checker.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
+
+type checkSet struct {
+	mx       sync.RWMutex
+	list     []*check
+	draining atomic.Bool
+}
+
+// lookup returns the checks of the registry, nil when missing
+func lookup(cdi cloudy.Registry) *checkSet {
+	checks, _ := cdi.LoadType(checksType).(*checkSet)
+	return checks
+}
+
+// loadMx serializes the creation of the check sets
+var loadMx sync.Mutex
+
+// load returns the checks of the registry, when missing the checks are created in the root of the
+// registry, so the checks added with a group or a request registry are seen by the component
+func load(cdi cloudy.Registry) *checkSet {
+	loadMx.Lock()
+	defer loadMx.Unlock()
+	if checks := lookup(cdi); checks != nil {
+		return checks
+	}
+	root := cdi
+	if container, ok := cdi.Container().(*registry.Registry); ok {
+		root = container.Root()
+	}
+	checks := &checkSet{}
+	root.WithTypeAndValue(checksType, checks)
+	return checks
+}
+
+func (checks *checkSet) snapshot(liveness bool) []*check {
+	checks.mx.RLock()
+	defer checks.mx.RUnlock()
+	list := make([]*check, 0, len(checks.list))
+	for _, check := range checks.list {
+		if check.Liveness || !liveness {
+			list = append(list, check)
+		}
+	}
+	return list
+}
+
+// AddCheck adds check to the checks of the registry, a check with the same name is replaced
+func AddCheck(registry cloudy.Registry, c Check) {
+	if c.Checker == nil {
+		panic(fmt.Errorf("health: check %q has no checker", c.Name))
+	}
+	checks := load(registry)
+	checks.mx.Lock()
+	defer checks.mx.Unlock()
+	for i, current := range checks.list {
+		if current.Name == c.Name {
+			checks.list[i] = &check{Check: c}
+			return
+		}
+	}
+	checks.list = append(checks.list, &check{Check: c})
+}
+
+// AddChecker adds a readiness check named name to the checks of the registry
+func AddChecker(registry cloudy.Registry, name string, checker Checker) {
+	AddCheck(registry, Check{Name: name, Checker: checker})
+}
+
+// SetDraining makes readiness fail while liveness is kept, so load balancers stop routing
+// new traffic before the process is stopped
+func SetDraining(registry cloudy.Registry) {
+	load(registry).draining.Store(true)
+}
+
+// IsDraining reports if SetDraining was called with the registry
+func IsDraining(registry cloudy.Registry) bool {
+	if checks := lookup(registry); checks != nil {
+		return checks.draining.Load()
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/health"
+	"github.com/CloudyKit/cloudy/session"
+	"github.com/CloudyKit/cloudy/session/store/file"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func serveReport(t *testing.T, kernel *cloudy.Kernel, path string) (int, health.Report) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	var report health.Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("%s: %v in %q", path, err, recorder.Body.String())
+	}
+	return recorder.Code, report
+}
+
+func TestComponent(t *testing.T) {
+	manager := session.New(time.Hour, time.Hour, file.New(t.TempDir()), session.GobSessionEncoder{}, session.RandGenerator{})
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&health.Component{CacheTTL: -1}, &session.Component{Manager: manager})
+	health.AddChecker(kernel.Registry, "session", manager.StoreChecker(kernel.Registry))
+
+	var failing atomic.Bool
+	health.AddChecker(kernel.Registry, "database", health.CheckerFunc(func(ctx context.Context) error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+	health.AddCheck(kernel.Registry, health.Check{Name: "process", Liveness: true, Checker: health.CheckerFunc(func(ctx context.Context) error {
+		return nil
+	})})
+
+	code, report := serveReport(t, kernel, "/readyz")
+	if code != 200 || report.Status != health.StatusOK || len(report.Checks) != 3 {
+		t.Fatalf("unexpected readiness %d %+v", code, report)
+	}
+	if report.Checks["session"].Status != health.StatusOK {
+		t.Errorf("unexpected session check %+v", report.Checks["session"])
+	}
+
+	code, report = serveReport(t, kernel, "/healthz")
+	if code != 200 || len(report.Checks) != 1 || report.Checks["process"].Status != health.StatusOK {
+		t.Errorf("unexpected liveness %d %+v", code, report)
+	}
+
+	failing.Store(true)
+	code, report = serveReport(t, kernel, "/readyz")
+	if code != 503 || report.Status != health.StatusFail || report.Checks["database"].Error != "connection refused" {
+		t.Errorf("unexpected failing readiness %d %+v", code, report)
+	}
+	if report.Checks["session"].Status != health.StatusOK {
+		t.Errorf("unexpected session check %+v", report.Checks["session"])
+	}
+	if code, _ = serveReport(t, kernel, "/healthz"); code != 200 {
+		t.Errorf("liveness should not run readiness checks, got %d", code)
+	}
+
+	failing.Store(false)
+	health.SetDraining(kernel.Registry)
+	if !health.IsDraining(kernel.Registry) {
+		t.Error("expected draining")
+	}
+	code, report = serveReport(t, kernel, "/readyz")
+	if code != 503 || report.Status != health.StatusDraining || report.Checks["database"].Status != health.StatusOK {
+		t.Errorf("unexpected draining readiness %d %+v", code, report)
+	}
+	if code, _ = serveReport(t, kernel, "/healthz"); code != 200 {
+		t.Errorf("draining should keep liveness, got %d", code)
+	}
+}
+
+func TestComponent_Group(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	var group *cloudy.Kernel
+	kernel.Group("/admin", func(admin *cloudy.Kernel) {
+		group = admin
+		// added before the component, the check is kept in the root registry
+		health.AddChecker(admin.Registry, "queue", health.CheckerFunc(func(ctx context.Context) error {
+			return nil
+		}))
+	})
+	kernel.AddComponents(&health.Component{})
+
+	if code, report := serveReport(t, kernel, "/readyz"); code != 200 || report.Checks["queue"].Status != health.StatusOK {
+		t.Fatalf("the check of the group was not reported %d %+v", code, report)
+	}
+	health.SetDraining(group.Registry)
+	if code, report := serveReport(t, kernel, "/readyz"); code != 503 || report.Status != health.StatusDraining {
+		t.Errorf("draining set with the group was not reported %d %+v", code, report)
+	}
+}
+
+func TestCheck_Timeout(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&health.Component{Timeout: 20 * time.Millisecond})
+
+	release := make(chan struct{})
+	defer close(release)
+	health.AddChecker(kernel.Registry, "stuck", health.CheckerFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+
+	start := time.Now()
+	code, report := serveReport(t, kernel, "/readyz")
+	if code != 503 || report.Checks["stuck"].Error != health.ErrTimeout.Error() {
+		t.Errorf("unexpected report %d %+v", code, report)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("check was not bounded by the timeout, took %s", elapsed)
+	}
+}
+
+func TestCheck_Cache(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&health.Component{CacheTTL: time.Hour})
+
+	var runs atomic.Int32
+	health.AddChecker(kernel.Registry, "counted", health.CheckerFunc(func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}))
+	health.AddCheck(kernel.Registry, health.Check{Name: "uncached", CacheTTL: -1, Checker: health.CheckerFunc(func(ctx context.Context) error {
+		return nil
+	})})
+
+	_, first := serveReport(t, kernel, "/readyz")
+	_, second := serveReport(t, kernel, "/readyz")
+	if runs.Load() != 1 {
+		t.Errorf("expected a single run, got %d", runs.Load())
+	}
+	if first.Checks["counted"].Cached || !second.Checks["counted"].Cached {
+		t.Errorf("unexpected cached flags %+v %+v", first.Checks["counted"], second.Checks["counted"])
+	}
+	if second.Checks["uncached"].Cached {
+		t.Errorf("check with negative ttl was cached %+v", second.Checks["uncached"])
+	}
+}
@@ -0,0 +1,183 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// HostParamsType is the registry type of the HostParams captured from the request host
+var HostParamsType = reflect.TypeOf(HostParams(nil))
+
+// HostParams holds the values captured by a wildcard host, ex: {tenant}.example.com
+type HostParams map[string]string
+
+// Get returns the value captured for name
+func (params HostParams) Get(name string) string {
+	return params[name]
+}
+
+// GetHostParams gets the HostParams of the request from the registry
+func GetHostParams(cdi Registry) HostParams {
+	params, _ := cdi.LoadType(HostParamsType).(HostParams)
+	return params
+}
+
+type hostParamsKey struct{}
+
+type hostRoute struct {
+	pattern string
+	labels  []string
+	kernel  *Kernel
+}
+
+type hostTable struct {
+	mx     sync.RWMutex
+	routes []hostRoute
+}
+
+// hostName returns the lower case host without the port
+func hostName(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func (table *hostTable) add(pattern string, kernel *Kernel) {
+	route := hostRoute{pattern: pattern, labels: strings.Split(hostName(pattern), "."), kernel: kernel}
+
+	table.mx.Lock()
+	defer table.mx.Unlock()
+
+	// exact hosts are matched before wildcard hosts
+	if !strings.Contains(pattern, "{") {
+		for i, other := range table.routes {
+			if strings.Contains(other.pattern, "{") {
+				table.routes = append(table.routes[:i], append([]hostRoute{route}, table.routes[i:]...)...)
+				return
+			}
+		}
+	}
+	table.routes = append(table.routes, route)
+}
+
+func (table *hostTable) match(host string) (*Kernel, HostParams) {
+	table.mx.RLock()
+	defer table.mx.RUnlock()
+
+	if len(table.routes) == 0 {
+		return nil, nil
+	}
+
+	labels := strings.Split(hostName(host), ".")
+	for _, route := range table.routes {
+		if len(route.labels) != len(labels) {
+			continue
+		}
+
+		var params HostParams
+		matched := true
+		for i, label := range route.labels {
+			if len(label) > 2 && label[0] == '{' && label[len(label)-1] == '}' && labels[i] != "" {
+				if params == nil {
+					params = HostParams{}
+				}
+				params[label[1:len(label)-1]] = labels[i]
+			} else if label != labels[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return route.kernel, params
+		}
+	}
+	return nil, nil
+}
+
+// Host serves the requests for the host pattern with the sub kernel, a pattern label written as
+// {name} matches any label and the value is available with Context.HostParams or GetHostParams,
+// ports are ignored and exact hosts are matched before wildcard hosts.
+// The url names and the routes of sub are added to this kernel with the host, this way urls
+// generated by this kernel point to the host, ex: //admin.example.com/users/5, link.Absolute
+// completes the scheme. Names already present in this kernel are kept, use sub.NamePrefix to
+// avoid conflicts. Url names and routes are copied, call Host after the routes of sub are added.
+// The sub registry inherits the kernel registry, values not provided by sub are loaded from the
+// kernel, as in Mount.
+//
+//	admin := cloudy.NewKernel()
+//	admin.NamePrefix = "admin."
+//	admin.AddControllers(&Users{})
+//	kernel.Host("admin.example.com", admin)
+//	kernel.Host("{tenant}.example.com", tenants)
+func (kernel *Kernel) Host(pattern string, sub *Kernel) *Kernel {
+	subRegistry, ok := sub.Registry.Container().(interface{ Inherit(Registry) error })
+	if !ok {
+		panic(fmt.Errorf("cloudy: adding kernel for host %s: registry %T can't inherit", pattern, sub.Registry))
+	}
+	if err := subRegistry.Inherit(kernel.Registry); err != nil {
+		panic(fmt.Errorf("cloudy: adding kernel for host %s: %w", pattern, err))
+	}
+
+	kernel.hosts.add(pattern, sub)
+
+	for name, path := range sub.URLGen {
+		if _, exists := kernel.URLGen[name]; !exists {
+			kernel.URLGen[name] = "//" + pattern + path
+		}
+	}
+
+	for _, route := range sub.Routes() {
+		if route.Host == "" {
+			route.Host = pattern
+		}
+		kernel.routes.append(route)
+	}
+	return sub
+}
+
+// ServeHTTP serves the request with the kernel added for the request host, see Host,
+// requests not matching any host are served by the kernel Router, requests without a
+// route are handled as described in NotFound, MethodNotAllowed
+func (kernel *Kernel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if sub, params := kernel.hosts.match(r.Host); sub != nil {
+		if params != nil {
+			r = r.WithContext(context.WithValue(r.Context(), hostParamsKey{}, params))
+		}
+		sub.ServeHTTP(w, r)
+		return
+	}
+
+	if handler, params := kernel.Router.FindRoute(r.Method, r.URL.Path); handler != nil {
+		handler(w, r, params)
+		return
+	}
+	kernel.serveFallback(w, r)
+}
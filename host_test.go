@@ -0,0 +1,91 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"github.com/CloudyKit/cloudy/link"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKernel_Host(t *testing.T) {
+	kernel := NewKernel()
+	kernel.Registry.WithTypeAndValue(mountConfigType, &mountConfig{Name: "app"})
+	kernel.AddHandlerFunc("GET", "/", func(c *Context) {
+		c.WriteString("main")
+	})
+
+	admin := NewKernel()
+	admin.NamePrefix = "admin."
+	admin.AddControllers(&groupUsers{})
+	kernel.Host("admin.example.com", admin)
+
+	tenants := NewKernel()
+	tenants.AddHandlerFunc("GET", "/", func(c *Context) {
+		config, _ := c.Registry.LoadType(mountConfigType).(*mountConfig)
+		if config == nil {
+			c.WriteString("the host registry doesn't inherit the kernel registry")
+			return
+		}
+		c.WriteString("tenant " + c.GetHostParameter("tenant") + " " + GetHostParams(c.Registry).Get("tenant"))
+	})
+	tenants.URLGen["Tenant.Home"] = "/"
+	kernel.Host("{tenant}.example.com", tenants)
+
+	serve := func(host, path string) string {
+		request := httptest.NewRequest("GET", path, nil)
+		request.Host = host
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, request)
+		return recorder.Body.String()
+	}
+
+	if body := serve("admin.example.com:8080", "/users/5"); body != "user 5" {
+		t.Fatalf("admin host: unexpected body %q", body)
+	}
+	if body := serve("Acme.Example.com", "/"); body != "tenant acme acme" {
+		t.Fatalf("wildcard host: unexpected body %q", body)
+	}
+	if body := serve("example.com", "/"); body != "main" {
+		t.Fatalf("default host: unexpected body %q", body)
+	}
+	if body := serve("a.b.example.com", "/"); body != "main" {
+		t.Fatalf("wildcard matched more than one label: %q", body)
+	}
+
+	if url := kernel.URLGen.URL("admin.Users.Show", 5); url != "//admin.example.com/users/5" {
+		t.Fatalf("unexpected url %q", url)
+	}
+	if url := kernel.URLGen.URL("Tenant.Home", link.Params{"tenant": "acme"}); url != "//acme.example.com/" {
+		t.Fatalf("unexpected url %q", url)
+	}
+
+	kernel.Registry.WithTypeAndValue(link.BaseURLProviderType, link.StaticBaseURL("https://example.com"))
+	if url := link.Absolute(kernel.Registry).URL("admin.Users.Show", 5); url != "https://admin.example.com/users/5" {
+		t.Fatalf("unexpected absolute url %q", url)
+	}
+
+	if routes := kernel.Routes(); len(routes) != 3 || routes[1].Host != "admin.example.com" {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+}
@@ -0,0 +1,228 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BodyLimit limits the request body to limit bytes, requests declaring a larger Content-Length are
+// answered with 413 without running the next handlers, bodies found larger while read make the
+// reads of Request.Body and GetBodyBytes fail with *http.MaxBytesError and are answered with 413
+// when the handlers did not write a response. The limit of the last BodyLimit of the route is used, ex: a controller
+// accepting uploads raises the limit of the kernel:
+//
+//	kernel.AddMiddleware(cloudy.BodyLimit(1 << 20))
+//	mx.AddMiddleware(cloudy.BodyLimit(64 << 20))
+func BodyLimit(limit int64) Handler {
+	return HandlerFunc(func(c *Context) {
+		if c.Request.ContentLength > limit {
+			requestTooLarge(c)
+			return
+		}
+
+		c.bodyLimit = limit
+		c.Next()
+
+		var maxBytesError *http.MaxBytesError
+		if errors.As(c.bodyErr, &maxBytesError) && !c.response.Written() {
+			requestTooLarge(c)
+		}
+	})
+}
+
+// requestTooLarge answers with 413, the connection is closed as the rest of the body is not read
+func requestTooLarge(c *Context) {
+	c.Response.Header().Set("Connection", "close")
+	_ = c.SendTextWithStatus(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+}
+
+// Timeout gives the next handlers timeout to write the response, GoContext is canceled when the
+// timeout expires and the client receives 503 when nothing was written yet, writes after the
+// timeout fail with http.ErrHandlerTimeout. Handlers keep running until they return, long operations
+// should watch GoContext. The response of the next handlers, Response and Writer, is locked against
+// the timeout and can't be hijacked. Nested timeouts can only shorten the deadline, ex:
+//
+//	kernel.Group("/reports", fn, cloudy.Timeout(30*time.Second))
+//	mx.AddMiddleware(cloudy.Timeout(2 * time.Second))
+func Timeout(timeout time.Duration) Handler {
+	return HandlerFunc(func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		request, response, recorded := c.Request, c.Response, c.response
+		writer := &timeoutWriter{ctx: ctx, w: response, response: recorded, header: response.Header().Clone()}
+		stop := context.AfterFunc(ctx, writer.timeout)
+
+		// the timeout writes from another goroutine, the next handlers reach the response only through writer
+		c.Request, c.Response, c.response = request.WithContext(ctx), writer, writer
+		defer func() {
+			stop()
+			writer.finish()
+			c.Request, c.Response, c.response = request, response, recorded
+		}()
+		c.Next()
+	})
+}
+
+// timeoutWriter keeps the header of the handlers apart until the response is written, the header
+// and the status are sent by the handlers or by timeout, whichever comes first. The recorded
+// response of the request is read under the same lock, see Context.Writer
+type timeoutWriter struct {
+	mx       sync.Mutex
+	ctx      context.Context
+	w        http.ResponseWriter
+	response ResponseWriter
+	header   http.Header
+	wrote    bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Status() int {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	return tw.response.Status()
+}
+
+func (tw *timeoutWriter) Size() int64 {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	return tw.response.Size()
+}
+
+func (tw *timeoutWriter) Written() bool {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	return tw.response.Written()
+}
+
+func (tw *timeoutWriter) Before(fn func(status int)) {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	tw.response.Before(fn)
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	if tw.expired() {
+		return
+	}
+	if !tw.wrote {
+		tw.writeHeader(status)
+	}
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	tw.copyHeader()
+	// informational responses are followed by the final one
+	if status >= 200 {
+		tw.wrote = true
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wrote {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	if tw.expired() {
+		return
+	}
+	if !tw.wrote {
+		tw.writeHeader(http.StatusOK)
+	}
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, see http.ResponseController
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// timeout answers with 503 when the deadline expired before the handlers wrote the response
+func (tw *timeoutWriter) timeout() {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	tw.expired()
+}
+
+// expired reports if the response was answered by the timeout, a handler noticing the deadline
+// before the timer fires finds the 503 already sent
+func (tw *timeoutWriter) expired() bool {
+	if tw.timedOut || tw.wrote || !errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		return tw.timedOut
+	}
+	tw.timedOut = true
+	body := http.StatusText(http.StatusServiceUnavailable)
+	header := tw.w.Header()
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("X-Content-Type-Options", "nosniff")
+	tw.w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = io.WriteString(tw.w, body)
+	// the client gets the whole response now, not when the handler returns
+	_ = http.NewResponseController(tw.w).Flush()
+	return true
+}
+
+// finish sends the header of handlers that returned without writing, the response is completed
+// by the server
+func (tw *timeoutWriter) finish() {
+	tw.mx.Lock()
+	defer tw.mx.Unlock()
+	if !tw.expired() && !tw.wrote {
+		tw.copyHeader()
+	}
+}
+
+func (tw *timeoutWriter) copyHeader() {
+	header := tw.w.Header()
+	clear(header)
+	for key, values := range tw.header {
+		header[key] = values
+	}
+}
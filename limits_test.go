@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type uploads struct {
+	Context *Context
+}
+
+func (u *uploads) Mx(mx *Mapper) {
+	mx.Name = "Uploads"
+	mx.AddMiddleware(BodyLimit(16))
+	mx.BindAction("POST", "/uploads", "Create")
+}
+
+func (u *uploads) Create() {
+	body, err := u.Context.GetBodyBytes()
+	if err != nil {
+		return
+	}
+	u.Context.Printf("%d", len(body))
+}
+
+func TestBodyLimit(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddMiddleware(BodyLimit(4))
+	kernel.AddHandlerFunc("POST", "/echo", func(c *Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return
+		}
+		c.Response.Write(body)
+	})
+	kernel.AddControllers(&uploads{})
+
+	serve := func(path string, body io.Reader) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, httptest.NewRequest("POST", path, body))
+		return recorder
+	}
+
+	if recorder := serve("/echo", strings.NewReader("1234")); recorder.Code != 200 || recorder.Body.String() != "1234" {
+		t.Errorf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	// the declared length is refused before the handler runs
+	if recorder := serve("/echo", strings.NewReader("12345")); recorder.Code != 413 || recorder.Header().Get("Connection") != "close" {
+		t.Errorf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+	// bodies without length are cut while read
+	if recorder := serve("/echo", io.MultiReader(strings.NewReader("12345"))); recorder.Code != 413 {
+		t.Errorf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	// the controller raises the limit of the kernel
+	if recorder := serve("/uploads", io.MultiReader(strings.NewReader("0123456789"))); recorder.Code != 200 || recorder.Body.String() != "10" {
+		t.Errorf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve("/uploads", io.MultiReader(strings.NewReader(strings.Repeat("x", 17)))); recorder.Code != 413 {
+		t.Errorf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	kernel := NewKernel()
+	kernel.Group("/slow", func(group *Kernel) {
+		group.AddHandlerFunc("GET", "/wait", func(c *Context) {
+			c.Response.Header().Set("X-Handler", "wait")
+			<-c.GoContext().Done()
+			if _, err := c.WriteString("late"); !errors.Is(err, http.ErrHandlerTimeout) {
+				t.Errorf("expected ErrHandlerTimeout, got %v", err)
+			}
+		})
+		group.AddHandlerFunc("GET", "/fast", func(c *Context) {
+			if _, ok := c.GoContext().Deadline(); !ok {
+				t.Error("expected a deadline in the go context")
+			}
+			c.Response.Header().Set("X-Handler", "fast")
+			c.WriteString("fast")
+		})
+		group.AddHandlerFunc("GET", "/empty", func(c *Context) {
+			c.Response.Header().Set("X-Handler", "empty")
+		})
+	}, Timeout(20*time.Millisecond))
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow/wait", nil))
+	if recorder.Code != 503 || recorder.Body.String() != "Service Unavailable" || recorder.Header().Get("X-Handler") != "" {
+		t.Errorf("unexpected response %d %q %v", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+
+	recorder = httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow/fast", nil))
+	if recorder.Code != 200 || recorder.Body.String() != "fast" || recorder.Header().Get("X-Handler") != "fast" {
+		t.Errorf("unexpected response %d %q %v", recorder.Code, recorder.Body.String(), recorder.Header())
+	}
+
+	recorder = httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/slow/empty", nil))
+	if recorder.Header().Get("X-Handler") != "empty" {
+		t.Errorf("the header of a handler that did not write was lost %v", recorder.Header())
+	}
+}
+
+func TestTimeout_Writer(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("GET", "/late", func(c *Context) {
+		<-c.GoContext().Done()
+		// the timeout writes from another goroutine, the recorded response is read under its lock
+		for !c.Writer().Written() {
+			time.Sleep(time.Millisecond)
+		}
+		if status := c.Writer().Status(); status != 503 {
+			t.Errorf("expected the status of the timeout, got %d", status)
+		}
+		if err := c.SendJSON("late"); !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Errorf("expected ErrHandlerTimeout, got %v", err)
+		}
+	}, Timeout(10*time.Millisecond))
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/late", nil))
+	if recorder.Code != 503 || recorder.Body.String() != "Service Unavailable" {
+		t.Errorf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestTimeout_Latency(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("GET", "/sleep", func(c *Context) {
+		// ignores the go context, the client must not wait for the handler
+		time.Sleep(time.Second)
+	}, Timeout(100*time.Millisecond))
+
+	server := httptest.NewServer(kernel)
+	defer server.Close()
+
+	start := time.Now()
+	response, err := http.Get(server.URL + "/sleep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the timeout response took %v", elapsed)
+	}
+	if response.StatusCode != 503 || string(body) != "Service Unavailable" {
+		t.Errorf("unexpected response %d %q", response.StatusCode, body)
+	}
+}
@@ -0,0 +1,147 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package link
+
+import (
+	"errors"
+	"github.com/CloudyKit/cloudy/registry"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	BaseURLProviderType = reflect.TypeOf((*BaseURLProvider)(nil)).Elem()
+	requestType         = reflect.TypeOf((*http.Request)(nil))
+)
+
+var (
+	errNoBaseURL      = errors.New("link: no base url available, configure a Fallback or generate the url while handling a request")
+	errNoBaseProvider = errors.New("link: no BaseURLProvider configured, register StaticBaseURL or opt in to RequestBaseURL as BaseURLProviderType")
+)
+
+// BaseURLProvider provides the scheme and host used to generate absolute urls,
+// providers are looked up in the registry as BaseURLProviderType
+type BaseURLProvider interface {
+	BaseURL(cdi registry.Interface) (*url.URL, error)
+}
+
+// StaticBaseURL is a configured base url, ex: link.StaticBaseURL("https://example.com")
+type StaticBaseURL string
+
+func (base StaticBaseURL) BaseURL(_ registry.Interface) (*url.URL, error) {
+	return url.Parse(string(base))
+}
+
+// RequestBaseURL derives the base url from the request being handled, when TrustForwarded is set
+// the X-Forwarded-Proto and X-Forwarded-Host headers set by a proxy are used,
+// Fallback is used when there is no request in the registry, ex: emails sent from a job.
+// The Host and the forwarded headers are set by the client, a link sent by email, ex: a password
+// reset, can point to any host, register RequestBaseURL only behind a proxy accepting known hosts.
+type RequestBaseURL struct {
+	TrustForwarded bool
+	Fallback       BaseURLProvider
+}
+
+func (base RequestBaseURL) BaseURL(cdi registry.Interface) (*url.URL, error) {
+	var request *http.Request
+	if cdi != nil {
+		request, _ = cdi.LoadType(requestType).(*http.Request)
+	}
+
+	if request == nil {
+		if base.Fallback != nil {
+			return base.Fallback.BaseURL(cdi)
+		}
+		return nil, errNoBaseURL
+	}
+
+	baseURL := &url.URL{Scheme: "http", Host: request.Host}
+	if request.TLS != nil {
+		baseURL.Scheme = "https"
+	}
+
+	if base.TrustForwarded {
+		if proto := request.Header.Get("X-Forwarded-Proto"); proto != "" {
+			baseURL.Scheme = strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+		}
+		if host := request.Header.Get("X-Forwarded-Host"); host != "" {
+			baseURL.Host = strings.TrimSpace(strings.Split(host, ",")[0])
+		}
+	}
+	return baseURL, nil
+}
+
+// GetBaseURL returns the base url from the BaseURLProvider available in the scope, there is no
+// default provider, the host of the request is used only when RequestBaseURL is registered
+func GetBaseURL(cdi registry.Interface) (*url.URL, error) {
+	if cdi != nil {
+		if provider, ok := cdi.LoadType(BaseURLProviderType).(BaseURLProvider); ok {
+			return provider.BaseURL(cdi)
+		}
+	}
+	return nil, errNoBaseProvider
+}
+
+// AbsoluteURL generates an absolute URL with the URLGen available in the scope, see BuildURL and GetBaseURL
+func AbsoluteURL(cdi registry.Interface, resource string, v ...interface{}) (string, error) {
+	return Absolute(cdi).Build(resource, v...)
+}
+
+// Absolute returns an URLGen generating absolute urls with the URLGen and the base url
+// available in the scope, ex: link.Absolute(registry).URL("Users.Show", link.Params{"id": 5})
+func Absolute(cdi registry.Interface) *AbsoluteURLGen {
+	return &AbsoluteURLGen{registry: cdi}
+}
+
+// AbsoluteURLGen generates absolute urls, see Absolute
+type AbsoluteURLGen struct {
+	registry registry.Interface
+}
+
+func (gen *AbsoluteURLGen) URL(resource string, v ...interface{}) string {
+	absoluteURL, _ := gen.Build(resource, v...)
+	return absoluteURL
+}
+
+func (gen *AbsoluteURLGen) Build(resource string, v ...interface{}) (string, error) {
+	generatedURL, err := BuildURL(gen.registry, resource, v...)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := url.Parse(generatedURL)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsAbs() {
+		return generatedURL, nil
+	}
+
+	baseURL, err := GetBaseURL(gen.registry)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,161 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package link
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/CloudyKit/cloudy/registry"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var AssetManifestType = reflect.TypeOf((*AssetManifest)(nil))
+
+// AssetManifest maps asset names to fingerprinted names containing a hash of the file
+// content, ex: css/app.css => css/app.3f9a1c2b.css, changing the file changes the url
+// which allows the asset to be cached forever.
+type AssetManifest struct {
+	Prefix string // Prefix url prefix where the assets are served, ex: /static
+	FS     fs.FS  // FS holds the asset files
+	Dev    bool   // Dev when set files are not hashed, urls are the plain file names
+
+	mx       sync.RWMutex
+	assets   map[string]string
+	reversed map[string]string
+}
+
+// NewAssetManifest creates a manifest hashing all files in fsys
+func NewAssetManifest(prefix string, fsys fs.FS) (*AssetManifest, error) {
+	manifest := &AssetManifest{Prefix: prefix, FS: fsys}
+	return manifest, manifest.Load()
+}
+
+// NewDevAssetManifest creates a manifest that skips hashing, urls point to the plain files
+func NewDevAssetManifest(prefix string, fsys fs.FS) *AssetManifest {
+	return &AssetManifest{Prefix: prefix, FS: fsys, Dev: true}
+}
+
+// Load hashes the files in the manifest FS, Load is called by NewAssetManifest, calling
+// Load again refreshes the manifest
+func (manifest *AssetManifest) Load() error {
+	assets := make(map[string]string)
+	reversed := make(map[string]string)
+
+	if !manifest.Dev {
+		err := fs.WalkDir(manifest.FS, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			hash, err := hashFile(manifest.FS, name)
+			if err != nil {
+				return err
+			}
+			fingerprinted := Fingerprint(name, hash)
+			assets[name] = fingerprinted
+			reversed[fingerprinted] = name
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	manifest.mx.Lock()
+	manifest.assets = assets
+	manifest.reversed = reversed
+	manifest.mx.Unlock()
+	return nil
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:8], nil
+}
+
+// Fingerprint inserts hash before the file extension, ex: Fingerprint("css/app.css", "3f9a1c2b") => css/app.3f9a1c2b.css
+func Fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Name returns the fingerprinted name of the asset, the plain name is returned in dev mode
+// or when the asset is not in the manifest
+func (manifest *AssetManifest) Name(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	manifest.mx.RLock()
+	fingerprinted, found := manifest.assets[name]
+	manifest.mx.RUnlock()
+	if found {
+		return fingerprinted
+	}
+	return name
+}
+
+// URL returns the url of the asset, ex: manifest.URL("css/app.css") => /static/css/app.3f9a1c2b.css
+func (manifest *AssetManifest) URL(name string) string {
+	return strings.TrimSuffix(manifest.Prefix, "/") + "/" + manifest.Name(name)
+}
+
+// Resolve maps a requested name to the file name, fingerprinted reports if the
+// requested name is a fingerprinted name
+func (manifest *AssetManifest) Resolve(requested string) (name string, fingerprinted bool) {
+	requested = strings.TrimPrefix(requested, "/")
+	manifest.mx.RLock()
+	name, fingerprinted = manifest.reversed[requested]
+	manifest.mx.RUnlock()
+	if fingerprinted {
+		return name, true
+	}
+	return requested, false
+}
+
+// GetAssetManifest gets the asset manifest from the scope
+func GetAssetManifest(cdi registry.Interface) *AssetManifest {
+	manifest, _ := cdi.LoadType(AssetManifestType).(*AssetManifest)
+	return manifest
+}
+
+// Asset returns the url of the asset with the manifest available in the scope,
+// ex: link.Asset(registry, "css/app.css") => /static/css/app.3f9a1c2b.css
+func Asset(cdi registry.Interface, name string) string {
+	if cdi != nil {
+		if manifest := GetAssetManifest(cdi); manifest != nil {
+			return manifest.URL(name)
+		}
+	}
+	return "/" + strings.TrimPrefix(name, "/")
+}
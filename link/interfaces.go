@@ -23,10 +23,11 @@
 package link
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/CloudyKit/cloudy/registry"
+	"net/url"
 	"reflect"
+	"strings"
 )
 
 var (
@@ -38,6 +39,11 @@ type URLGen interface {
 	URL(resource string, v ...interface{}) string // URL generates an URL
 }
 
+// Builder is implemented by url generators able to report errors, ex: a missing route parameter
+type Builder interface {
+	Build(resource string, v ...interface{}) (string, error) // Build generates an URL
+}
+
 // Gets an URL generator from the scope
 func GetURLGen(cdi registry.Interface) URLGen {
 	urlGen, _ := cdi.LoadType(URLGenType).(URLGen)
@@ -65,24 +71,51 @@ func GenURL(cdi registry.Interface, resource string, v ...interface{}) string {
 	return urLer.URL(resource, v...)
 }
 
+// BuildURL generates an URL with the URLGen available in the scope, errors are reported
+// when the URLGen implements Builder
+func BuildURL(cdi registry.Interface, resource string, v ...interface{}) (string, error) {
+	var urLer URLGen
+	if cdi != nil {
+		urLer = GetURLGen(cdi)
+	}
+
+	if builder, ok := urLer.(Builder); ok {
+		return builder.Build(resource, v...)
+	}
+	if urLer != nil {
+		return urLer.URL(resource, v...), nil
+	}
+	return Expand(resource, v...)
+}
+
 // BaseURL holds an base url, invoking this func will return the base url with query string,
-// ex: NewBaseURL("/search")("q", "my search input","page",5) will result in /search?q=my search input&page=5
+// ex: NewBaseURL("/search")("q", "my search input","page",5) will result in /search?q=my+search+input&page=5
 type BaseURL func(...interface{}) string
 
-// NewBaseURL creates a new BaseURL, see type BaseURL func(...interface{}) string
-func NewBaseURL(url string) BaseURL {
+// NewBaseURL creates a new BaseURL, see type BaseURL func(...interface{}) string,
+// keys and values are query escaped, a key without value is encoded with an empty value
+func NewBaseURL(baseURL string) BaseURL {
 	return func(v ...interface{}) string {
 		numOfArgs := len(v)
 		if numOfArgs > 0 {
-			buf := bytes.NewBuffer(nil)
-			buf.WriteString(url)
-			fmt.Fprintf(buf, "?%s=%s", v[0], v[1])
-			for i := 2; i+1 < numOfArgs; i += 2 {
-				fmt.Fprintf(buf, "&%s=%s", v[i], v[i+1])
+			var buf strings.Builder
+			buf.WriteString(baseURL)
+			separator := byte('?')
+			if strings.IndexByte(baseURL, '?') != -1 {
+				separator = '&'
+			}
+			for i := 0; i < numOfArgs; i += 2 {
+				buf.WriteByte(separator)
+				buf.WriteString(url.QueryEscape(fmt.Sprint(v[i])))
+				buf.WriteByte('=')
+				if i+1 < numOfArgs {
+					buf.WriteString(url.QueryEscape(fmt.Sprint(v[i+1])))
+				}
+				separator = '&'
 			}
 			return buf.String()
 		}
-		return url
+		return baseURL
 	}
 }
 
@@ -0,0 +1,264 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package link
+
+import (
+	"container/list"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Params holds route parameters by name, ex: URL("Users.Show", link.Params{"id": 5})
+type Params map[string]interface{}
+
+// Query holds query string values, ex: URL("Users.Show", link.Params{"id": 5}, link.Query{"tab": "a b"}),
+// slices of strings are encoded as repeated keys
+type Query map[string]interface{}
+
+// Encode encodes the query sorted by key
+func (query Query) Encode() string {
+	values := url.Values{}
+	for key, value := range query {
+		switch value := value.(type) {
+		case []string:
+			values[key] = append(values[key], value...)
+		case []interface{}:
+			for _, v := range value {
+				values.Add(key, fmt.Sprint(v))
+			}
+		default:
+			values.Add(key, fmt.Sprint(value))
+		}
+	}
+	return values.Encode()
+}
+
+type patternPart struct {
+	literal  string
+	name     string
+	param    bool
+	wildcard bool
+}
+
+// routePattern is a route path split in literals and parameters, ex: /users/:id/*file or
+// //{tenant}.example.com/users/:id
+type routePattern []patternPart
+
+// maxPatterns is the number of parsed patterns kept, resources expanded as patterns are arbitrary
+// strings, the least recently used patterns are dropped
+const maxPatterns = 1024
+
+var patterns = struct {
+	sync.Mutex
+	lru    *list.List
+	parsed map[string]*list.Element
+}{lru: list.New(), parsed: map[string]*list.Element{}}
+
+type parsedPattern struct {
+	pattern string
+	parts   routePattern
+}
+
+func (parts routePattern) has(name string) bool {
+	for _, part := range parts {
+		if part.param && part.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePattern(pattern string) routePattern {
+	patterns.Lock()
+	defer patterns.Unlock()
+	if element, ok := patterns.parsed[pattern]; ok {
+		patterns.lru.MoveToFront(element)
+		return element.Value.(*parsedPattern).parts
+	}
+
+	parts := splitPattern(pattern)
+	patterns.parsed[pattern] = patterns.lru.PushFront(&parsedPattern{pattern: pattern, parts: parts})
+	if patterns.lru.Len() > maxPatterns {
+		oldest := patterns.lru.Back()
+		patterns.lru.Remove(oldest)
+		delete(patterns.parsed, oldest.Value.(*parsedPattern).pattern)
+	}
+	return parts
+}
+
+// splitPattern splits pattern in literals and parameters, {name} parameters are parsed only in
+// the host of the pattern, ex: //{tenant}.example.com
+func splitPattern(pattern string) routePattern {
+	hostEnd := 0
+	if strings.HasPrefix(pattern, "//") {
+		hostEnd = strings.IndexByte(pattern[2:], '/')
+		if hostEnd == -1 {
+			hostEnd = len(pattern)
+		} else {
+			hostEnd += 2
+		}
+	}
+
+	var parts routePattern
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		if (pattern[i] == ':' || pattern[i] == '*') && i > 0 && pattern[i-1] == '/' {
+			if start < i {
+				parts = append(parts, patternPart{literal: pattern[start:i]})
+			}
+			end := strings.IndexByte(pattern[i:], '/')
+			if end == -1 {
+				end = len(pattern)
+			} else {
+				end += i
+			}
+			parts = append(parts, patternPart{name: pattern[i+1 : end], param: true, wildcard: pattern[i] == '*'})
+			start = end
+			i = end - 1
+		} else if pattern[i] == '{' && i < hostEnd {
+			// host parameters, ex: //{tenant}.example.com/users/:id
+			end := strings.IndexByte(pattern[i:hostEnd], '}')
+			if end == -1 {
+				break
+			}
+			end += i
+			if start < i {
+				parts = append(parts, patternPart{literal: pattern[start:i]})
+			}
+			parts = append(parts, patternPart{name: pattern[i+1 : end], param: true})
+			start = end + 1
+			i = end
+		}
+	}
+	if start < len(pattern) {
+		parts = append(parts, patternPart{literal: pattern[start:]})
+	}
+	return parts
+}
+
+// Expand fills the route pattern with the arguments, arguments can be a Params, a Query, url.Values
+// or positional values filling the parameters in order. Parameters are path escaped, wildcard
+// parameters keep the slashes and drop a leading one, host parameters written as {name} come
+// first in the positional order. Missing or extra parameters are reported as errors.
+//
+//	Expand("/users/:id", link.Params{"id": 5}, link.Query{"tab": "a b"}) => /users/5?tab=a+b
+//	Expand("/files/*path", "docs/read me.txt") => /files/docs/read%20me.txt
+//	Expand("/files/*path", "/docs/read me.txt") => /files/docs/read%20me.txt
+//	Expand("//{tenant}.example.com/users/:id", "acme", 5) => //acme.example.com/users/5
+func Expand(pattern string, v ...interface{}) (string, error) {
+	var (
+		params     Params
+		query      []string
+		positional []interface{}
+	)
+
+	for _, arg := range v {
+		switch arg := arg.(type) {
+		case Params:
+			if params == nil {
+				params = Params{}
+			}
+			for key, value := range arg {
+				params[key] = value
+			}
+		case Query:
+			if encoded := arg.Encode(); encoded != "" {
+				query = append(query, encoded)
+			}
+		case url.Values:
+			if encoded := arg.Encode(); encoded != "" {
+				query = append(query, encoded)
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if params != nil && len(positional) > 0 {
+		return "", fmt.Errorf("link: %q mixes named and positional parameters", pattern)
+	}
+
+	var (
+		buf  strings.Builder
+		used int
+	)
+	for _, part := range parsePattern(pattern) {
+		if !part.param {
+			buf.WriteString(part.literal)
+			continue
+		}
+
+		var (
+			value interface{}
+			found bool
+		)
+		if params != nil {
+			value, found = params[part.name]
+		} else if used < len(positional) {
+			value, found = positional[used], true
+		}
+		if !found {
+			return "", fmt.Errorf("link: missing parameter %q in %q", part.name, pattern)
+		}
+		used++
+
+		if part.wildcard {
+			// the value of a wildcard matched by the router starts with a slash
+			segments := strings.Split(strings.TrimPrefix(fmt.Sprint(value), "/"), "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			buf.WriteString(strings.Join(segments, "/"))
+		} else {
+			buf.WriteString(url.PathEscape(fmt.Sprint(value)))
+		}
+	}
+
+	if params != nil && used < len(params) {
+		var extra []string
+		parts := parsePattern(pattern)
+		for key := range params {
+			if !parts.has(key) {
+				extra = append(extra, key)
+			}
+		}
+		sort.Strings(extra)
+		return "", fmt.Errorf("link: unknown parameters %q in %q", extra, pattern)
+	}
+	if used < len(positional) {
+		return "", fmt.Errorf("link: %d extra parameters in %q", len(positional)-used, pattern)
+	}
+
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(buf.String(), "?") {
+			separator = "&"
+		}
+		buf.WriteString(separator)
+		buf.WriteString(strings.Join(query, "&"))
+	}
+	return buf.String(), nil
+}
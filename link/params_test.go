@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package link
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		pattern string
+		args    []interface{}
+		want    string
+	}{
+		{"/users/:id", []interface{}{Params{"id": 5}, Query{"tab": "a b"}}, "/users/5?tab=a+b"},
+		{"/users/:id/posts/:post", []interface{}{"a/b", 2}, "/users/a%2Fb/posts/2"},
+		{"/files/*path", []interface{}{Params{"path": "docs/read me.txt"}}, "/files/docs/read%20me.txt"},
+		{"/files/*path", []interface{}{"/docs/read me.txt"}, "/files/docs/read%20me.txt"},
+		{"/search?lang=en", []interface{}{Query{"q": []string{"x&y", "z"}}}, "/search?lang=en&q=x%26y&q=z"},
+		{"/about", nil, "/about"},
+		{"//{tenant}.example.com/users/:id", []interface{}{"acme", 5}, "//acme.example.com/users/5"},
+		{"//{tenant}.example.com/users/:id", []interface{}{Params{"tenant": "acme", "id": 5}}, "//acme.example.com/users/5"},
+		{"/docs/{draft}/:page", []interface{}{2}, "/docs/{draft}/2"},
+		{"//{tenant}.example.com/{draft}", []interface{}{"acme"}, "//acme.example.com/{draft}"},
+	}
+
+	for _, test := range tests {
+		got, err := Expand(test.pattern, test.args...)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.pattern, err)
+		} else if got != test.want {
+			t.Errorf("%s: want %q got %q", test.pattern, test.want, got)
+		}
+	}
+}
+
+func TestParsePatternBounded(t *testing.T) {
+	for i := 0; i < maxPatterns+10; i++ {
+		parsePattern(fmt.Sprintf("/resource/%d/:id", i))
+	}
+	parsePattern("/users/:id")
+	patterns.Lock()
+	defer patterns.Unlock()
+	if patterns.lru.Len() != maxPatterns || len(patterns.parsed) != maxPatterns {
+		t.Fatalf("expected %d patterns, got %d", maxPatterns, patterns.lru.Len())
+	}
+	if _, ok := patterns.parsed["/users/:id"]; !ok {
+		t.Error("the last pattern was not kept")
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		args    []interface{}
+	}{
+		{"/users/:id", nil},
+		{"/users/:id", []interface{}{1, 2}},
+		{"/users/:id", []interface{}{Params{"id": 1, "name": "x"}}},
+		{"/users/:id", []interface{}{Params{"name": "x"}}},
+		{"/users/:id", []interface{}{Params{"id": 1}, 2}},
+	}
+
+	for _, test := range tests {
+		if got, err := Expand(test.pattern, test.args...); err == nil {
+			t.Errorf("%s %v: expected an error got %q", test.pattern, test.args, got)
+		}
+	}
+}
+
+func TestNewBaseURLEscaping(t *testing.T) {
+	generatedURL := NewBaseURL("/search")("q", "my search&input", "page", 5)
+	expectedURL := "/search?q=my+search%26input&page=5"
+	if generatedURL != expectedURL {
+		t.Errorf("want %q got %q", expectedURL, generatedURL)
+	}
+}
@@ -0,0 +1,161 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package link
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("link: invalid url signature")
+	ErrExpiredSignature = errors.New("link: url signature expired")
+
+	errNoSigningKey = errors.New("link: signing key is not configured")
+)
+
+// DefaultSigner is the signer used by Signed, configure the key at boot
+// ex: link.DefaultSigner.Key = []byte(os.Getenv("URL_SIGNING_KEY"))
+var DefaultSigner = &Signer{}
+
+// Signer signs urls with an HMAC-SHA256 of the path and the query, the signature and
+// the expiry time are appended in the query string
+type Signer struct {
+	Key            []byte
+	ExpiresParam   string // ExpiresParam query param holding the expiry unix time, default "expires"
+	SignatureParam string // SignatureParam query param holding the signature, default "signature"
+}
+
+func (signer *Signer) params() (expires, signature string) {
+	expires, signature = signer.ExpiresParam, signer.SignatureParam
+	if expires == "" {
+		expires = "expires"
+	}
+	if signature == "" {
+		signature = "signature"
+	}
+	return
+}
+
+// mac signs the path and the query with the keys sorted
+func (signer *Signer) mac(path string, query url.Values) []byte {
+	mac := hmac.New(sha256.New, signer.Key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(query.Encode()))
+	return mac.Sum(nil)
+}
+
+// Sign signs rawURL valid for ttl, when rawURL is absolute the scheme and host are not signed
+func (signer *Signer) Sign(rawURL string, ttl time.Duration) (string, error) {
+	if len(signer.Key) == 0 {
+		return "", errNoSigningKey
+	}
+
+	signedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	expiresParam, signatureParam := signer.params()
+	query := signedURL.Query()
+	query.Del(signatureParam)
+	query.Set(expiresParam, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+
+	signature := base64.RawURLEncoding.EncodeToString(signer.mac(signedURL.EscapedPath(), query))
+	signedURL.RawQuery = query.Encode() + "&" + url.QueryEscape(signatureParam) + "=" + signature
+	return signedURL.String(), nil
+}
+
+// Verify checks the signature and the expiry time of the url requested by the client, the
+// RequestURI is checked when set as a prefix can be stripped from r.URL, ex: Kernel.Mount
+func (signer *Signer) Verify(r *http.Request) error {
+	if r.RequestURI == "" {
+		return signer.VerifyURL(r.URL)
+	}
+	requestURL, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return signer.VerifyURL(requestURL)
+}
+
+// VerifyURL checks the signature and the expiry time of u
+func (signer *Signer) VerifyURL(u *url.URL) error {
+	if len(signer.Key) == 0 {
+		return errNoSigningKey
+	}
+
+	expiresParam, signatureParam := signer.params()
+	query := u.Query()
+
+	signature, err := base64.RawURLEncoding.DecodeString(query.Get(signatureParam))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidSignature
+	}
+	query.Del(signatureParam)
+
+	if !hmac.Equal(signature, signer.mac(u.EscapedPath(), query)) {
+		return ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(query.Get(expiresParam), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expires {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+// Signed generates the url named name with urlGen and signs it with DefaultSigner valid for ttl,
+// ex: link.Signed(link.Absolute(registry), "Downloads.Get", time.Hour, link.Params{"id": 5})
+func Signed(urlGen URLGen, name string, ttl time.Duration, params ...interface{}) (string, error) {
+	return DefaultSigner.Signed(urlGen, name, ttl, params...)
+}
+
+// Signed generates the url named name with urlGen and signs it valid for ttl
+func (signer *Signer) Signed(urlGen URLGen, name string, ttl time.Duration, params ...interface{}) (string, error) {
+	var (
+		generatedURL string
+		err          error
+	)
+	if builder, ok := urlGen.(Builder); ok {
+		generatedURL, err = builder.Build(name, params...)
+	} else if urlGen != nil {
+		generatedURL = urlGen.URL(name, params...)
+	} else {
+		generatedURL, err = Expand(name, params...)
+	}
+	if err != nil {
+		return "", err
+	}
+	return signer.Sign(generatedURL, ttl)
+}
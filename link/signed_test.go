@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package link
+
+import (
+	"github.com/CloudyKit/cloudy/registry"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testURLGen map[string]string
+
+func (gen testURLGen) URL(resource string, v ...interface{}) string {
+	generatedURL, _ := gen.Build(resource, v...)
+	return generatedURL
+}
+
+func (gen testURLGen) Build(resource string, v ...interface{}) (string, error) {
+	return Expand(gen[resource], v...)
+}
+
+func TestSigner(t *testing.T) {
+	signer := &Signer{Key: []byte("secret")}
+	urlGen := testURLGen{"Downloads.Get": "/downloads/:id"}
+
+	signedURL, err := signer.Signed(urlGen, "Downloads.Get", time.Hour, Params{"id": 5}, Query{"name": "a b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signedURL, "/downloads/5?") {
+		t.Fatalf("unexpected url %q", signedURL)
+	}
+	if err = signer.Verify(httptest.NewRequest("GET", signedURL, nil)); err != nil {
+		t.Fatalf("valid url rejected: %v", err)
+	}
+
+	tampered := strings.Replace(signedURL, "/downloads/5", "/downloads/6", 1)
+	if err = signer.Verify(httptest.NewRequest("GET", tampered, nil)); err != ErrInvalidSignature {
+		t.Fatalf("tampered url: want ErrInvalidSignature got %v", err)
+	}
+
+	expiredURL, _ := signer.Signed(urlGen, "Downloads.Get", -time.Minute, Params{"id": 5})
+	if err = signer.Verify(httptest.NewRequest("GET", expiredURL, nil)); err != ErrExpiredSignature {
+		t.Fatalf("expired url: want ErrExpiredSignature got %v", err)
+	}
+
+	if _, err = (&Signer{}).Sign("/downloads/5", time.Hour); err == nil {
+		t.Fatal("expected an error signing without key")
+	}
+}
+
+func TestAbsolute(t *testing.T) {
+	reg := registry.New()
+	defer reg.Dispose()
+	reg.WithTypeAndValue(URLGenType, testURLGen{"Users.Show": "/users/:id"})
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Host = "app.example.com"
+	request.Header.Set("X-Forwarded-Proto", "https")
+	reg.WithTypeAndValue(requestType, request)
+
+	// the host of the request is set by the client, it's used only when RequestBaseURL is registered
+	if absoluteURL, err := AbsoluteURL(reg, "Users.Show", Params{"id": 5}); err != errNoBaseProvider {
+		t.Fatalf("expected an error without provider, got %q %v", absoluteURL, err)
+	}
+
+	reg.WithTypeAndValue(BaseURLProviderType, RequestBaseURL{})
+	absoluteURL, err := AbsoluteURL(reg, "Users.Show", Params{"id": 5})
+	if err != nil || absoluteURL != "http://app.example.com/users/5" {
+		t.Fatalf("unexpected url %q %v", absoluteURL, err)
+	}
+
+	reg.WithTypeAndValue(BaseURLProviderType, RequestBaseURL{TrustForwarded: true})
+	if absoluteURL = Absolute(reg).URL("Users.Show", Params{"id": 5}); absoluteURL != "https://app.example.com/users/5" {
+		t.Fatalf("unexpected url %q", absoluteURL)
+	}
+
+	reg.WithTypeAndValue(BaseURLProviderType, StaticBaseURL("https://cdn.example.com/app/"))
+	signedURL, err := (&Signer{Key: []byte("k")}).Signed(Absolute(reg), "Users.Show", time.Hour, Params{"id": 5})
+	if err != nil || !strings.HasPrefix(signedURL, "https://cdn.example.com/users/5?expires=") {
+		t.Fatalf("unexpected url %q %v", signedURL, err)
+	}
+	parsed, _ := url.Parse(signedURL)
+	if err = (&Signer{Key: []byte("k")}).VerifyURL(parsed); err != nil {
+		t.Fatalf("absolute signed url rejected: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// SlogType is the registry type of the structured logger, the kernel provides slog.Default,
+// ex: kernel.Registry.WithTypeAndValue(cloudy.SlogType, slog.New(handler))
+var SlogType = reflect.TypeOf((*slog.Logger)(nil))
+
+// GetSlog returns the structured logger available in the registry, inside a request the logger
+// carries the route name, the method, the path and the request id
+func GetSlog(cdi Registry) *slog.Logger {
+	if logger, _ := cdi.LoadType(SlogType).(*slog.Logger); logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// requestSlog returns the logger of the request, derived from the logger of the registry
+// where the route was added, the logger is built once and again only when the request id changes
+func requestSlog(c *Context, parent *slog.Logger) *slog.Logger {
+	requestID := c.RequestID
+	if requestID == "" {
+		// without RequestIDMiddleware the header is logged only when it's a valid id
+		if id := c.Request.Header.Get(RequestIDHeader); validRequestID(id) {
+			requestID = id
+		}
+	}
+	if logger := c.logger.Load(); logger != nil && logger.requestID == requestID {
+		return logger.Logger
+	}
+
+	attrs := make([]any, 0, 8)
+	attrs = append(attrs,
+		slog.String("route", c.Name),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+	)
+	if requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+	logger := &requestLogger{Logger: parent.With(attrs...), requestID: requestID}
+	c.logger.Store(logger)
+	return logger.Logger
+}
+
+// requestLogger is the logger of a request and the request id it carries
+type requestLogger struct {
+	*slog.Logger
+	requestID string
+}
+
+// AccessLogMiddleware logs a line with the status, the bytes written and the latency when the
+// request finishes, the line is logged with the request logger, see GetSlog
+//
+//	kernel.AddMiddleware(cloudy.AccessLogMiddleware(slog.LevelInfo))
+func AccessLogMiddleware(level slog.Level) HandlerFunc {
+	return func(c *Context) {
+		start := time.Now()
+		defer func() {
+			writer := c.Writer()
+			status := writer.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			GetSlog(c.Registry).LogAttrs(c.Request.Context(), level, "access",
+				slog.Int("status", status),
+				slog.Int64("bytes", writer.Size()),
+				slog.Duration("latency", time.Since(start)),
+			)
+		}()
+
+		c.Next()
+	}
+}
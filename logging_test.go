@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	kernel := NewKernel()
+	kernel.Registry.WithTypeAndValue(SlogType, slog.New(slog.NewJSONHandler(&logs, nil)))
+	kernel.AddMiddleware(RequestIDMiddleware(nil), AccessLogMiddleware(slog.LevelInfo))
+	kernel.AddHandlerName("users.show", "GET", "/users/:id", HandlerFunc(func(c *Context) {
+		GetSlog(c.Registry).Info("loading user", "id", c.GetURLParameter("id"))
+		c.Response.WriteHeader(201)
+		c.WriteString("created")
+	}))
+
+	request := httptest.NewRequest("GET", "/users/5", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	kernel.ServeHTTP(httptest.NewRecorder(), request)
+
+	var lines []map[string]interface{}
+	decoder := json.NewDecoder(&logs)
+	for decoder.More() {
+		var line map[string]interface{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("want 2 log lines got %d", len(lines))
+	}
+
+	for _, line := range lines {
+		if line["route"] != "users.show" || line["method"] != "GET" || line["path"] != "/users/5" || line["request_id"] != "req-1" {
+			t.Errorf("missing request attributes %v", line)
+		}
+	}
+	if lines[0]["msg"] != "loading user" || lines[0]["id"] != "5" {
+		t.Errorf("unexpected line %v", lines[0])
+	}
+	access := lines[1]
+	if access["msg"] != "access" || access["status"] != float64(201) || access["bytes"] != float64(7) || access["latency"] == nil {
+		t.Errorf("unexpected access line %v", access)
+	}
+}
+
+func TestGetSlog_WithoutRequestIDMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	kernel := NewKernel()
+	kernel.Registry.WithTypeAndValue(SlogType, slog.New(slog.NewJSONHandler(&logs, nil)))
+	kernel.AddHandlerFunc("GET", "/", func(c *Context) {
+		if GetSlog(c.Registry) != GetSlog(c.Registry) {
+			t.Error("the request logger should be built once")
+		}
+		GetSlog(c.Registry).Info("home")
+	})
+
+	for _, id := range []string{"req-2", "forged\nline", strings.Repeat("x", 129)} {
+		logs.Reset()
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set(RequestIDHeader, id)
+		kernel.ServeHTTP(httptest.NewRecorder(), request)
+
+		var line map[string]interface{}
+		if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		requestID, logged := line["request_id"]
+		if valid := id == "req-2"; logged != valid || (valid && requestID != id) {
+			t.Errorf("%q: unexpected request id in %v", id, line)
+		}
+	}
+}
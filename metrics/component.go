@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package metrics
+
+import (
+	"context"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/event"
+	"github.com/CloudyKit/cloudy/session"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Component records the requests of the kernel per route pattern, the events dispatched and the latency
+// of the session store, the metrics are served in Path, requests to Path are not recorded. The Set
+// is available in the registry, custom metrics are added with GetSet.
+//
+//	kernel.AddComponents(&metrics.Component{})
+type Component struct {
+	Set     *Set      // Set holds the metrics, default NewSet()
+	Path    string    // Path where the metrics are served, default /metrics
+	Buckets []float64 // Buckets of the latency histograms, default DefaultBuckets
+
+	requests *Counter
+	duration *Histogram
+	inFlight *Gauge
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	if component.Set == nil {
+		component.Set = NewSet()
+	}
+	if component.Path == "" {
+		component.Path = "/metrics"
+	}
+	set := component.Set
+
+	kernel.Registry.WithTypeAndValue(SetType, set)
+
+	component.requests = set.Counter("cloudy_http_requests_total", "Requests handled by route, method and status.", "route", "method", "status")
+	component.duration = set.Histogram("cloudy_http_request_duration_seconds", "Request latencies by route and method.", component.Buckets, "route", "method")
+	component.inFlight = set.Gauge("cloudy_http_requests_in_flight", "Requests being handled by route.", "route")
+
+	events := set.Counter("cloudy_events_dispatched_total", "Events dispatched by event name and result.", "event", "result")
+	if dispatcher, ok := kernel.Registry.LoadType(event.EmitterType).(*event.Dispatcher); ok {
+		dispatcher.Observe(func(ctx context.Context, eventName string) (context.Context, func(bool, error)) {
+			return ctx, func(canceled bool, err error) {
+				events.Inc(eventName, dispatchResult(canceled, err))
+			}
+		})
+	}
+
+	sessionDuration := set.Histogram("cloudy_session_store_duration_seconds", "Session store latencies by operation.", component.Buckets, "operation")
+	sessionErrors := set.Counter("cloudy_session_store_errors_total", "Session store errors by operation.", "operation")
+	session.ObserveStore(kernel.Registry, func(_ cloudy.Registry, operation string) func(error) {
+		start := time.Now()
+		return func(err error) {
+			sessionDuration.Observe(time.Since(start).Seconds(), operation)
+			if err != nil {
+				sessionErrors.Inc(operation)
+			}
+		}
+	})
+
+	kernel.Root().AddMiddleware(component)
+	kernel.AddHandlerName("metrics", "GET", component.Path, cloudy.HandlerFunc(component.serve))
+}
+
+func (component *Component) Handle(c *cloudy.Context) {
+	// the pattern keeps the label bounded, the fallback handlers are labeled with their name
+	route := c.Pattern
+	if route == "" {
+		route = c.Name
+	}
+	start := time.Now()
+	component.inFlight.Inc(route)
+	defer component.inFlight.Dec(route)
+
+	c.Next()
+
+	status := c.Writer().Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	method := methodLabel(c.Request.Method)
+	component.requests.Inc(route, method, strconv.Itoa(status))
+	component.duration.Observe(time.Since(start).Seconds(), route, method)
+}
+
+func (component *Component) serve(c *cloudy.Context) {
+	c.Response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = component.Set.WriteTo(c.Response)
+}
+
+// methodLabel limits the method label to the standard methods
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+func dispatchResult(canceled bool, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case canceled:
+		return "canceled"
+	}
+	return "ok"
+}
@@ -0,0 +1,330 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package metrics keeps counters, gauges and histograms in process and exposes them in the
+// Prometheus text format, see Component.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var SetType = reflect.TypeOf((*Set)(nil))
+
+// GetSet returns the metrics Set available in the registry, ex: metrics.GetSet(c.Registry).Counter("orders_total", "Orders placed.").Inc()
+func GetSet(cdi cloudy.Registry) *Set {
+	set, _ := cdi.LoadType(SetType).(*Set)
+	return set
+}
+
+// DefaultBuckets are the histogram buckets used when no buckets are given, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Set holds metrics by name
+type Set struct {
+	mx       sync.RWMutex
+	families map[string]*family
+}
+
+func NewSet() *Set {
+	return &Set{families: make(map[string]*family)}
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mx     sync.RWMutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       atomicFloat
+	sum         atomicFloat
+	count       atomic.Uint64
+	buckets     []atomic.Uint64
+}
+
+// atomicFloat is a float64 updated atomically
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat) store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// family returns the metric named name, metrics are created on the first use, using a name
+// with other kind or other labels panics
+func (set *Set) family(name, help, kind string, buckets []float64, labels []string) *family {
+	set.mx.RLock()
+	f, ok := set.families[name]
+	set.mx.RUnlock()
+
+	if !ok {
+		set.mx.Lock()
+		if f, ok = set.families[name]; !ok {
+			f = &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+			set.families[name] = f
+		}
+		set.mx.Unlock()
+	}
+
+	if f.kind != kind || strings.Join(f.labels, ",") != strings.Join(labels, ",") {
+		panic(fmt.Errorf("metrics: %s registered as %s with labels %v", name, f.kind, f.labels))
+	}
+	return f
+}
+
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Errorf("metrics: %s expects labels %v got values %v", f.name, f.labels, labelValues))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	f.mx.RLock()
+	s, ok := f.series[key]
+	f.mx.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if s, ok = f.series[key]; !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.buckets = make([]atomic.Uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up, ex: requests served
+type Counter struct {
+	family *family
+}
+
+// Counter returns the counter named name, labels are the label names of the counter
+func (set *Set) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{family: set.family(name, help, kindCounter, nil, labels)}
+}
+
+// Inc adds one to the counter with the label values
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the label values, negative values panic
+func (counter *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Errorf("metrics: counter %s can't decrease", counter.family.name))
+	}
+	counter.family.with(labelValues).value.add(v)
+}
+
+// Gauge is a value that goes up and down, ex: requests in flight
+type Gauge struct {
+	family *family
+}
+
+// Gauge returns the gauge named name, labels are the label names of the gauge
+func (set *Set) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{family: set.family(name, help, kindGauge, nil, labels)}
+}
+
+func (gauge *Gauge) Set(v float64, labelValues ...string) {
+	gauge.family.with(labelValues).value.store(v)
+}
+
+func (gauge *Gauge) Add(v float64, labelValues ...string) {
+	gauge.family.with(labelValues).value.add(v)
+}
+
+func (gauge *Gauge) Inc(labelValues ...string) {
+	gauge.Add(1, labelValues...)
+}
+
+func (gauge *Gauge) Dec(labelValues ...string) {
+	gauge.Add(-1, labelValues...)
+}
+
+// Histogram counts observations in buckets, ex: request latencies
+type Histogram struct {
+	family *family
+}
+
+// Histogram returns the histogram named name, buckets are the upper bounds of the buckets,
+// DefaultBuckets is used when buckets is nil
+func (set *Set) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{family: set.family(name, help, kindHistogram, buckets, labels)}
+}
+
+// Observe adds the observation v to the histogram with the label values
+func (histogram *Histogram) Observe(v float64, labelValues ...string) {
+	s := histogram.family.with(labelValues)
+	if i := sort.SearchFloat64s(histogram.family.buckets, v); i < len(s.buckets) {
+		s.buckets[i].Add(1)
+	}
+	s.sum.add(v)
+	s.count.Add(1)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (set *Set) WriteTo(w io.Writer) (int64, error) {
+	set.mx.RLock()
+	families := make([]*family, 0, len(set.families))
+	for _, f := range set.families {
+		families = append(families, f)
+	}
+	set.mx.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	writer := &countWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(writer)
+	}
+	if writer.err == nil {
+		writer.err = writer.w.Flush()
+	}
+	return writer.n, writer.err
+}
+
+func (f *family) write(w *countWriter) {
+	f.mx.RLock()
+	series := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		series = append(series, s)
+	}
+	f.mx.RUnlock()
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labelValues, "\xff") < strings.Join(series[j].labelValues, "\xff")
+	})
+
+	if f.help != "" {
+		w.printf("# HELP %s %s\n", f.name, helpEscaper.Replace(f.help))
+	}
+	w.printf("# TYPE %s %s\n", f.name, f.kind)
+
+	for _, s := range series {
+		if f.kind != kindHistogram {
+			w.printf("%s%s %s\n", f.name, f.labelPairs(s.labelValues, ""), formatFloat(s.value.load()))
+			continue
+		}
+
+		var cumulative uint64
+		for i, upperBound := range f.buckets {
+			cumulative += s.buckets[i].Load()
+			w.printf("%s_bucket%s %d\n", f.name, f.labelPairs(s.labelValues, formatFloat(upperBound)), cumulative)
+		}
+		count := s.count.Load()
+		w.printf("%s_bucket%s %d\n", f.name, f.labelPairs(s.labelValues, "+Inf"), count)
+		w.printf("%s_sum%s %s\n", f.name, f.labelPairs(s.labelValues, ""), formatFloat(s.sum.load()))
+		w.printf("%s_count%s %d\n", f.name, f.labelPairs(s.labelValues, ""), count)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// labelPairs formats the labels, le is added when not empty
+func (f *family) labelPairs(labelValues []string, le string) string {
+	if len(labelValues) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, value := range labelValues {
+		pairs = append(pairs, f.labels[i]+`="`+labelEscaper.Replace(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countWriter) printf(format string, v ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, v...)
+	w.n += int64(n)
+	w.err = err
+}
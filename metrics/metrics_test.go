@@ -0,0 +1,137 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package metrics
+
+import (
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/event"
+	"github.com/CloudyKit/cloudy/session"
+	"github.com/CloudyKit/cloudy/session/store/file"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSet_WriteTo(t *testing.T) {
+	set := NewSet()
+	set.Counter("jobs_total", "Jobs done.", "queue").Add(2, "mail")
+	set.Counter("jobs_total", "Jobs done.", "queue").Inc(`a"b`)
+	set.Gauge("workers", "Workers running.").Set(3)
+	histogram := set.Histogram("job_seconds", "Job latencies.", []float64{1, 0.5})
+	histogram.Observe(0.2)
+	histogram.Observe(0.7)
+	histogram.Observe(3)
+
+	var out strings.Builder
+	if _, err := set.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP job_seconds Job latencies.
+# TYPE job_seconds histogram
+job_seconds_bucket{le="0.5"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.9
+job_seconds_count 3
+# HELP jobs_total Jobs done.
+# TYPE jobs_total counter
+jobs_total{queue="a\"b"} 1
+jobs_total{queue="mail"} 2
+# HELP workers Workers running.
+# TYPE workers gauge
+workers 3
+`
+	if out.String() != want {
+		t.Fatalf("unexpected exposition:\n%s", out.String())
+	}
+}
+
+func TestSet_Conflicts(t *testing.T) {
+	set := NewSet()
+	set.Counter("requests_total", "", "route")
+
+	for name, fn := range map[string]func(){
+		"kind":         func() { set.Gauge("requests_total", "", "route") },
+		"labels":       func() { set.Counter("requests_total", "", "method") },
+		"label values": func() { set.Counter("requests_total", "", "route").Inc("a", "b") },
+		"decrease":     func() { set.Counter("requests_total", "", "route").Add(-1, "a") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: want panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+type orderPlaced struct {
+	event.Event
+}
+
+func TestComponent(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	component := &Component{}
+	kernel.AddComponents(component, &session.Component{
+		Manager: session.New(time.Hour, time.Hour, file.New(t.TempDir()), session.GobSessionEncoder{}, session.RandGenerator{}),
+	})
+	kernel.AddHandlerName("orders.create", "POST", "/orders", cloudy.HandlerFunc(func(c *cloudy.Context) {
+		GetSet(c.Registry).Counter("orders_total", "Orders placed.").Inc()
+		kernel.Dispatch("order.placed", new(orderPlaced))
+		c.Response.WriteHeader(201)
+	}))
+	kernel.AddHandlerName("orders.show", "GET", "/orders/:id", cloudy.HandlerFunc(func(c *cloudy.Context) {}))
+
+	for i := 0; i < 2; i++ {
+		kernel.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/orders", nil))
+	}
+	kernel.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/1", nil))
+	kernel.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/2", nil))
+	kernel.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	exposition := recorder.Body.String()
+
+	for _, line := range []string{
+		`cloudy_http_requests_total{route="/orders",method="POST",status="201"} 2`,
+		`cloudy_http_requests_total{route="/orders/:id",method="GET",status="200"} 2`,
+		`cloudy_http_requests_total{route="NotFound",method="GET",status="404"} 1`,
+		`cloudy_http_request_duration_seconds_count{route="/orders",method="POST"} 2`,
+		`cloudy_http_requests_in_flight{route="/orders"} 0`,
+		`cloudy_events_dispatched_total{event="order.placed",result="ok"} 2`,
+		`cloudy_session_store_duration_seconds_count{operation="save"} 5`,
+		`orders_total 2`,
+	} {
+		if !strings.Contains(exposition, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, exposition)
+		}
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+}
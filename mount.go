@@ -0,0 +1,189 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"context"
+	"fmt"
+	"github.com/CloudyKit/cloudy/link"
+	"github.com/CloudyKit/router"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountMethods are the methods routed to mounted handlers
+var mountMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// sortedMountMethods is the method set of a kernel without routes, see allowedMethods
+var sortedMountMethods = []string{"CONNECT", "DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE"}
+
+// Mount serves the requests under prefix with the child kernel, the prefix is stripped from
+// the request path before the child router runs. The child registry inherits the kernel registry,
+// values not provided by the child are loaded from the kernel. The url names of child are
+// prefixed with the mount path and added to the kernel in the namespace derived from prefix,
+// ex: "Invoices.Show" mounted in /billing is "billing.Invoices.Show" in the kernel. Url names
+// and routes are copied, call Mount after the routes of child are added. The child is not
+// modified, the urls generated while serving a mounted request are prefixed with the mount path,
+// a kernel can be mounted in more than one path and still be served on its own.
+//
+//	billing := cloudy.NewKernel()
+//	billing.AddControllers(&Invoices{})
+//	kernel.Mount("/billing", billing)
+func (kernel *Kernel) Mount(prefix string, child *Kernel) *Kernel {
+	mountPath := kernel.Prefix + strings.TrimSuffix(prefix, "/")
+
+	childRegistry, ok := child.Registry.Container().(interface{ Inherit(Registry) error })
+	if !ok {
+		panic(fmt.Errorf("cloudy: mounting kernel in %s: registry %T can't inherit", mountPath, child.Registry))
+	}
+	if err := childRegistry.Inherit(kernel.Registry); err != nil {
+		panic(fmt.Errorf("cloudy: mounting kernel in %s: %w", mountPath, err))
+	}
+
+	namespace := kernel.namePrefix() + strings.ReplaceAll(strings.Trim(prefix, "/"), "/", ".") + "."
+	for name, pattern := range child.URLGen {
+		// urls of other hosts are absolute, see Host
+		if !strings.HasPrefix(pattern, "//") {
+			pattern = mountPath + pattern
+		}
+		kernel.URLGen[namespace+name] = pattern
+	}
+
+	for _, route := range child.Routes() {
+		route.Path = mountPath + route.Path
+		if route.Name != "" {
+			route.Name = namespace + route.Name
+		}
+		kernel.routes.append(route)
+	}
+
+	kernel.mount(mountPath, child)
+	return child
+}
+
+// MountHandler serves the requests under prefix with handler, the prefix is stripped from the
+// request path, ex: kernel.MountHandler("/debug/pprof", pprofMux)
+func (kernel *Kernel) MountHandler(prefix string, handler http.Handler) {
+	mountPath := kernel.Prefix + strings.TrimSuffix(prefix, "/")
+
+	for _, method := range mountMethods {
+		kernel.routes.append(Route{
+			Method:  method,
+			Path:    mountPath + "/*",
+			Handler: fmt.Sprintf("%T", handler),
+			Source:  routeSource(),
+		})
+	}
+
+	kernel.mount(mountPath, handler)
+}
+
+func (kernel *Kernel) mount(mountPath string, handler http.Handler) {
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		if lookup, ok := w.(*routeLookup); ok {
+			// only kernels are looked up, other handlers would serve the request, see MatchRoute
+			if child, ok := handler.(*Kernel); ok {
+				if route, found := child.MatchRoute(r.Method, stripPrefix(r, mountPath).URL.Path); found {
+					route.Path = mountPath + route.Path
+					lookup.route, lookup.found = route, true
+				}
+			}
+			return
+		}
+		// mounts can be nested, the path of the mount serving r is prefixed
+		parentPath, _ := r.Context().Value(mountPathKey{}).(string)
+		r = stripPrefix(r, mountPath)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), mountPathKey{}, parentPath+mountPath)))
+	}
+	for _, method := range mountMethods {
+		if mountPath != "" {
+			kernel.Router.AddRoute(method, mountPath, func(w http.ResponseWriter, r *http.Request, _ router.Parameter) {
+				serve(w, r)
+			})
+		}
+		kernel.Router.AddRoute(method, mountPath+"/*path", func(w http.ResponseWriter, r *http.Request, _ router.Parameter) {
+			serve(w, r)
+		})
+	}
+}
+
+// mountPathKey carries the path where the kernel serving the request is mounted
+type mountPathKey struct{}
+
+// mountURLGen prefixes the root relative urls of the kernel serving a mounted request with the
+// mount path, urls of other hosts are left untouched, see Host
+type mountURLGen struct {
+	urlGen    link.URLGen
+	mountPath string
+}
+
+func (urlGen *mountURLGen) URL(dst string, v ...interface{}) string {
+	generatedURL, _ := urlGen.Build(dst, v...)
+	return generatedURL
+}
+
+func (urlGen *mountURLGen) Build(dst string, v ...interface{}) (string, error) {
+	var (
+		generatedURL string
+		err          error
+	)
+	if builder, ok := urlGen.urlGen.(link.Builder); ok {
+		generatedURL, err = builder.Build(dst, v...)
+	} else if urlGen.urlGen != nil {
+		generatedURL = urlGen.urlGen.URL(dst, v...)
+	} else {
+		generatedURL, err = expandResource(dst, v)
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(generatedURL, "/") && !strings.HasPrefix(generatedURL, "//") {
+		generatedURL = urlGen.mountPath + generatedURL
+	}
+	return generatedURL, nil
+}
+
+// stripPrefix returns a shallow copy of r with prefix removed from the path, the stripped
+// path is at least "/"
+func stripPrefix(r *http.Request, prefix string) *http.Request {
+	if prefix == "" {
+		return r
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+	if r2.URL.Path == "" {
+		r2.URL.Path = "/"
+	}
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		if r2.URL.RawPath == "" {
+			r2.URL.RawPath = "/"
+		}
+	}
+	return r2
+}
@@ -0,0 +1,137 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"github.com/CloudyKit/cloudy/link"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mountConfig struct {
+	Name string
+}
+
+var mountConfigType = reflect.TypeOf((*mountConfig)(nil))
+
+func TestKernel_Mount(t *testing.T) {
+	kernel := NewKernel()
+	kernel.Registry.WithTypeAndValue(mountConfigType, &mountConfig{Name: "app"})
+	kernel.AddHandlerFunc("GET", "/ping", func(c *Context) {
+		c.WriteString("pong")
+	})
+
+	billing := NewKernel()
+	billing.AddControllers(&groupUsers{})
+	billing.AddHandlerFunc("GET", "/", func(c *Context) {
+		config := c.Registry.LoadType(mountConfigType).(*mountConfig)
+		c.WriteString(config.Name + " " + c.Request.URL.Path + " " + link.GetURLGen(c.Registry).URL("Users.Show", 5))
+	})
+	kernel.Mount("/billing", billing)
+	kernel.Mount("/invoicing", billing)
+
+	kernel.MountHandler("/legacy/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("legacy " + r.URL.Path))
+	}))
+
+	serve := func(method, path string) string {
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder.Body.String()
+	}
+
+	if body := serve("GET", "/billing/users/5"); body != "user 5" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if body := serve("GET", "/billing"); body != "app / /billing/users/5" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if body := serve("POST", "/legacy/a/b"); body != "legacy /a/b" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if body := serve("GET", "/ping"); body != "pong" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	if url := kernel.URLGen.URL("billing.Users.Show", 5); url != "/billing/users/5" {
+		t.Fatalf("unexpected url %q", url)
+	}
+
+	// the child is not modified, it can be mounted again or served on its own
+	if body := serve("GET", "/invoicing"); body != "app / /invoicing/users/5" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if url := kernel.URLGen.URL("invoicing.Users.Show", 5); url != "/invoicing/users/5" {
+		t.Fatalf("unexpected url %q", url)
+	}
+	if url := billing.URLGen.URL("Users.Show", 5); url != "/users/5" {
+		t.Fatalf("the url names of the child were modified %q", url)
+	}
+	recorder := httptest.NewRecorder()
+	billing.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	if body := recorder.Body.String(); body != "app / /users/5" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	var found bool
+	for _, route := range kernel.Routes() {
+		if route.Name == "billing.Users.Show" && route.Path == "/billing/users/:id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("mounted routes missing in %+v", kernel.Routes())
+	}
+}
+
+func TestKernel_MountSignedURL(t *testing.T) {
+	signer := &link.Signer{Key: []byte("secret")}
+
+	files := NewKernel()
+	files.AddHandlerFunc("GET", "/download", func(c *Context) {
+		c.WriteString("file")
+	}, SignedURLMiddleware(signer))
+
+	kernel := NewKernel()
+	kernel.Mount("/files", files)
+
+	signedURL, err := signer.Sign("/files/download", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", signedURL, nil))
+	if recorder.Code != 200 || recorder.Body.String() != "file" {
+		t.Fatalf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/files/download?"+strings.SplitN(signedURL, "?", 2)[1]+"x", nil))
+	if recorder.Code != 403 {
+		t.Fatalf("tampered url: want 403 got %d", recorder.Code)
+	}
+}
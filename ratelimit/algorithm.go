@@ -0,0 +1,192 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Algorithm decides if a request is allowed, Take receives the state returned by the previous call
+// for the same key, nil for a new key, and returns the new state. Stores keep the state of each
+// key and serialize the calls of the same key.
+type Algorithm interface {
+	Take(state interface{}, now time.Time) (interface{}, Result)
+	// Policy is the value of the RateLimit-Policy header, ex: 100;w=60
+	Policy() string
+	// TTL is how long the state of an idle key matters, after TTL the key is back to the full quota
+	TTL() time.Duration
+}
+
+// Result is the decision of an algorithm for a request
+type Result struct {
+	Allowed    bool
+	Limit      int           // Limit is the quota of the key
+	Remaining  int           // Remaining requests after this one
+	Reset      time.Duration // Reset is the time until the full quota is available again
+	RetryAfter time.Duration // RetryAfter is the time until the next request is allowed, when not allowed
+}
+
+// TokenBucket allows bursts of Burst requests, the bucket is refilled with Rate tokens every Per
+type TokenBucket struct {
+	Rate  int
+	Per   time.Duration
+	Burst int // Burst default Rate
+}
+
+type tokenBucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+func (bucket TokenBucket) burst() float64 {
+	if bucket.Burst > 0 {
+		return float64(bucket.Burst)
+	}
+	return float64(bucket.Rate)
+}
+
+func (bucket TokenBucket) validate() error {
+	if bucket.Rate <= 0 || bucket.Per <= 0 || bucket.Burst < 0 {
+		return fmt.Errorf("TokenBucket requires a positive Rate and Per, got Rate %d Per %s Burst %d", bucket.Rate, bucket.Per, bucket.Burst)
+	}
+	return nil
+}
+
+// duration returns the time to refill tokens
+func (bucket TokenBucket) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens * float64(bucket.Per) / float64(bucket.Rate)))
+}
+
+func (bucket TokenBucket) Take(state interface{}, now time.Time) (interface{}, Result) {
+	burst := bucket.burst()
+	current, _ := state.(*tokenBucketState)
+	if current == nil {
+		current = &tokenBucketState{tokens: burst, last: now}
+	}
+
+	if elapsed := now.Sub(current.last); elapsed > 0 {
+		current.tokens = math.Min(burst, current.tokens+float64(elapsed)*float64(bucket.Rate)/float64(bucket.Per))
+		current.last = now
+	}
+
+	result := Result{Limit: int(burst)}
+	if current.tokens >= 1 {
+		current.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = bucket.duration(1 - current.tokens)
+	}
+	result.Remaining = int(current.tokens)
+	result.Reset = bucket.duration(burst - current.tokens)
+	return current, result
+}
+
+func (bucket TokenBucket) Policy() string {
+	return strconv.Itoa(bucket.Rate) + ";w=" + seconds(bucket.Per) + ";burst=" + strconv.Itoa(int(bucket.burst()))
+}
+
+func (bucket TokenBucket) TTL() time.Duration {
+	return bucket.duration(bucket.burst())
+}
+
+// SlidingWindow allows Limit requests in any Window, the count of the previous window is weighted
+// by how much of it overlaps the sliding window
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+}
+
+type slidingWindowState struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+func (window SlidingWindow) validate() error {
+	if window.Limit <= 0 || window.Window <= 0 {
+		return fmt.Errorf("SlidingWindow requires a positive Limit and Window, got Limit %d Window %s", window.Limit, window.Window)
+	}
+	return nil
+}
+
+func (window SlidingWindow) Take(state interface{}, now time.Time) (interface{}, Result) {
+	current, _ := state.(*slidingWindowState)
+	if current == nil {
+		current = &slidingWindowState{start: now.Truncate(window.Window)}
+	}
+
+	switch elapsed := now.Sub(current.start); {
+	case elapsed >= 2*window.Window:
+		current.start, current.current, current.previous = now.Truncate(window.Window), 0, 0
+	case elapsed >= window.Window:
+		current.start, current.current, current.previous = current.start.Add(window.Window), 0, current.current
+	}
+
+	elapsed := now.Sub(current.start)
+	weight := 1 - float64(elapsed)/float64(window.Window)
+	estimate := float64(current.previous)*weight + float64(current.current)
+
+	result := Result{Limit: window.Limit}
+	if estimate+1 <= float64(window.Limit) {
+		current.current++
+		estimate++
+		result.Allowed = true
+	} else if free := float64(window.Limit - 1 - current.current); free < 0 || current.previous == 0 {
+		// the current window is full, the next one starts with the current count as previous
+		result.RetryAfter = window.Window - elapsed
+	} else {
+		// waits until the weight of the previous window leaves room for a request
+		result.RetryAfter = time.Duration((1-free/float64(current.previous))*float64(window.Window)) - elapsed
+	}
+	if result.RetryAfter < 0 {
+		result.RetryAfter = 0
+	}
+	// the requests of the current window count until the end of the next one
+	switch {
+	case current.current > 0:
+		result.Reset = 2*window.Window - elapsed
+	case current.previous > 0:
+		result.Reset = window.Window - elapsed
+	}
+	result.Remaining = window.Limit - int(math.Ceil(estimate))
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	return current, result
+}
+
+func (window SlidingWindow) Policy() string {
+	return strconv.Itoa(window.Limit) + ";w=" + seconds(window.Window)
+}
+
+func (window SlidingWindow) TTL() time.Duration {
+	return 2 * window.Window
+}
+
+// seconds formats d in whole seconds rounded up, as used by the RateLimit headers
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
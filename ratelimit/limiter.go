@@ -0,0 +1,227 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package ratelimit limits the requests of a client with token bucket or sliding window algorithms.
+//
+//	kernel.AddComponents(&ratelimit.Component{Limiter: &ratelimit.Limiter{
+//		Algorithm: ratelimit.TokenBucket{Rate: 100, Per: time.Minute},
+//	}})
+//
+// Routes attach stricter limits as middleware, ex:
+//
+//	func (c *Auth) Mx(mx *cloudy.Mapper) {
+//		mx.BindAction("POST", "/login", "Login", &ratelimit.Limiter{
+//			Algorithm: ratelimit.SlidingWindow{Limit: 5, Window: time.Minute},
+//			Key:       ratelimit.FirstKey(ratelimit.BySession, ratelimit.ByIP),
+//		})
+//	}
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/session"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	headerLimit      = "RateLimit-Limit"
+	headerRemaining  = "RateLimit-Remaining"
+	headerReset      = "RateLimit-Reset"
+	headerPolicy     = "RateLimit-Policy"
+	headerRetryAfter = "Retry-After"
+)
+
+// KeyFunc returns the key of the client of the request, an empty key skips the limit
+type KeyFunc func(c *cloudy.Context) string
+
+// ByIP keys the requests by the ip address of the connection
+func ByIP(c *cloudy.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ByHeader keys the requests by the first address in header, ex: X-Forwarded-For set by a trusted
+// proxy, the connection ip is used when the header is missing
+func ByHeader(header string) KeyFunc {
+	return func(c *cloudy.Context) string {
+		value, _, _ := strings.Cut(c.Request.Header.Get(header), ",")
+		if value = strings.TrimSpace(value); value != "" {
+			return "ip:" + value
+		}
+		return ByIP(c)
+	}
+}
+
+// BySession keys the requests by the session id, the session component must run before the limiter.
+// A client dropping the cookie gets a new session, combine it with an ip limit for anonymous clients.
+func BySession(c *cloudy.Context) string {
+	if s, _ := c.Registry.LoadType(session.SessionType).(*session.Session); s != nil && s.ID() != "" {
+		return "session:" + s.ID()
+	}
+	return ""
+}
+
+// ByUser keys the requests by the authenticated user returned by user, anonymous requests have an empty user
+func ByUser(user func(c *cloudy.Context) string) KeyFunc {
+	return func(c *cloudy.Context) string {
+		if id := user(c); id != "" {
+			return "user:" + id
+		}
+		return ""
+	}
+}
+
+// FirstKey returns the first non empty key of keys, ex: FirstKey(ByUser(currentUser), ByIP)
+func FirstKey(keys ...KeyFunc) KeyFunc {
+	return func(c *cloudy.Context) string {
+		for _, key := range keys {
+			if k := key(c); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+var (
+	defaultStoreOnce sync.Once
+	defaultStore     Store
+)
+
+// Limiter is a middleware limiting the requests of each client key, requests over the limit are
+// answered with 429 Too Many Requests. The result is sent in the RateLimit headers, when more than
+// one limiter applies the headers of the most restrictive are kept. Store errors are logged and
+// the request is allowed, the limiter fails open unless FailClosed is set. Each limiter counts the
+// requests of all the routes it applies to, set Name when the store is shared by other processes,
+// the default name is only unique in the process. An invalid Algorithm panics in Component.Bootstrap,
+// or in the first request of limiters added as route middlewares.
+type Limiter struct {
+	Algorithm  Algorithm      // Algorithm required, ex: TokenBucket or SlidingWindow
+	Key        KeyFunc        // Key default ByIP
+	Name       string         // Name namespaces the keys in the store, default derived from the limiter and the policy
+	Store      Store          // Store default the store of the registry, see Component
+	OnLimited  cloudy.Handler // OnLimited handles the requests over the limit, default 429 text response
+	FailClosed bool           // FailClosed answers 503 Service Unavailable when the store fails
+
+	once        sync.Once
+	defaultName string
+}
+
+// init validates the algorithm and derives the default name
+func (limiter *Limiter) init() {
+	if limiter.Algorithm == nil {
+		panic("ratelimit: Limiter.Algorithm is required, ex: ratelimit.TokenBucket{Rate: 100, Per: time.Minute}")
+	}
+	if algorithm, ok := limiter.Algorithm.(interface{ validate() error }); ok {
+		if err := algorithm.validate(); err != nil {
+			panic(fmt.Errorf("ratelimit: %w", err))
+		}
+	}
+	limiter.defaultName = fmt.Sprintf("%p;%s", limiter, limiter.Algorithm.Policy())
+}
+
+func (limiter *Limiter) store(c *cloudy.Context) Store {
+	if limiter.Store != nil {
+		return limiter.Store
+	}
+	if store := GetStore(c.Registry); store != nil {
+		return store
+	}
+	defaultStoreOnce.Do(func() {
+		defaultStore = NewMemoryStore(0)
+	})
+	return defaultStore
+}
+
+func (limiter *Limiter) Handle(c *cloudy.Context) {
+	keyFunc := limiter.Key
+	if keyFunc == nil {
+		keyFunc = ByIP
+	}
+	key := keyFunc(c)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	limiter.once.Do(limiter.init)
+	// limiters sharing a name share the counters of the keys
+	name := limiter.Name
+	if name == "" {
+		name = limiter.defaultName
+	}
+
+	result, err := limiter.store(c).Take(c.Request.Context(), name+":"+key, limiter.Algorithm)
+	if err != nil {
+		cloudy.GetSlog(c.Registry).Error("rate limit", "limiter", name, "error", err)
+		if limiter.FailClosed {
+			http.Error(c.Response, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	header := c.Response.Header()
+	if remaining, err := strconv.Atoi(header.Get(headerRemaining)); err != nil || result.Remaining <= remaining || !result.Allowed {
+		header.Set(headerLimit, strconv.Itoa(result.Limit))
+		header.Set(headerRemaining, strconv.Itoa(result.Remaining))
+		header.Set(headerReset, seconds(result.Reset))
+		header.Set(headerPolicy, limiter.Algorithm.Policy())
+	}
+
+	if !result.Allowed {
+		header.Set(headerRetryAfter, seconds(result.RetryAfter))
+		if limiter.OnLimited != nil {
+			limiter.OnLimited.Handle(c)
+			return
+		}
+		http.Error(c.Response, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	c.Next()
+}
+
+// Component provides the Store to the limiters of the kernel and applies Limiter to all the requests
+type Component struct {
+	Store   Store    // Store default NewMemoryStore(0)
+	Limiter *Limiter // Limiter applied to all the requests of the kernel, optional
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	if component.Store == nil {
+		component.Store = NewMemoryStore(0)
+	}
+	kernel.Registry.WithTypeAndValue(StoreType, component.Store)
+	if component.Limiter != nil {
+		component.Limiter.once.Do(component.Limiter.init)
+		kernel.Root().AddMiddleware(component.Limiter)
+	}
+}
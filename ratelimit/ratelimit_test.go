@@ -0,0 +1,256 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/session"
+	"github.com/CloudyKit/cloudy/session/store/file"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := TokenBucket{Rate: 1, Per: time.Second, Burst: 2}
+	now := time.Unix(1000, 0)
+
+	var state interface{}
+	var result Result
+	for i := 0; i < 2; i++ {
+		if state, result = bucket.Take(state, now); !result.Allowed || result.Remaining != 1-i {
+			t.Fatalf("request %d: unexpected %+v", i, result)
+		}
+	}
+	if state, result = bucket.Take(state, now); result.Allowed || result.RetryAfter != time.Second || result.Reset != 2*time.Second {
+		t.Fatalf("unexpected %+v", result)
+	}
+	if state, result = bucket.Take(state, now.Add(500*time.Millisecond)); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("unexpected %+v", result)
+	}
+	if _, result = bucket.Take(state, now.Add(time.Second)); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("refilled token was not allowed %+v", result)
+	}
+	if policy := bucket.Policy(); policy != "1;w=1;burst=2" {
+		t.Errorf("unexpected policy %q", policy)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	window := SlidingWindow{Limit: 4, Window: time.Minute}
+	start := time.Unix(6000, 0) // aligned to the window
+
+	var state interface{}
+	var result Result
+	for i := 0; i < 4; i++ {
+		state, result = window.Take(state, start.Add(time.Duration(i)*time.Second))
+		if !result.Allowed || result.Remaining != 3-i {
+			t.Fatalf("request %d: unexpected %+v", i, result)
+		}
+	}
+	if state, result = window.Take(state, start.Add(30*time.Second)); result.Allowed || result.RetryAfter != 30*time.Second {
+		t.Fatalf("unexpected %+v", result)
+	}
+
+	// a quarter into the next window the previous 4 requests weigh 3
+	if state, result = window.Take(state, start.Add(75*time.Second)); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("unexpected %+v", result)
+	}
+	if state, result = window.Take(state, start.Add(80*time.Second)); result.Allowed || result.RetryAfter != 10*time.Second {
+		t.Fatalf("unexpected %+v", result)
+	}
+
+	// after two windows the state is reset
+	if _, result = window.Take(state, start.Add(200*time.Second)); !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("unexpected %+v", result)
+	}
+}
+
+func TestMemoryStore_Expiration(t *testing.T) {
+	store := NewMemoryStore(1)
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+	bucket := TokenBucket{Rate: 1, Per: time.Hour}
+
+	if result, _ := store.Take(context.Background(), "a", bucket); !result.Allowed {
+		t.Fatal("first request was not allowed")
+	}
+	if result, _ := store.Take(context.Background(), "a", bucket); result.Allowed {
+		t.Fatal("second request was allowed")
+	}
+	if result, _ := store.Take(context.Background(), "b", bucket); !result.Allowed {
+		t.Fatal("keys are not independent")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if result, _ := store.Take(context.Background(), "c", bucket); !result.Allowed {
+		t.Fatal("request was not allowed")
+	}
+	entries := 0
+	for i := range store.shards {
+		entries += len(store.shards[i].entries)
+	}
+	if entries != 1 {
+		t.Errorf("expired keys were not swept, %d entries", entries)
+	}
+}
+
+type account struct {
+	Context *cloudy.Context
+}
+
+func (a *account) Mx(mx *cloudy.Mapper) {
+	mx.Name = "Account"
+	mx.BindAction("POST", "/login", "Login", &Limiter{Algorithm: SlidingWindow{Limit: 1, Window: time.Hour}})
+	mx.BindAction("GET", "/profile", "Profile")
+}
+
+func (a *account) Login()   { a.Context.WriteString("login") }
+func (a *account) Profile() { a.Context.WriteString("profile") }
+
+func serve(kernel *cloudy.Kernel, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, nil)
+	request.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestLimiter(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{Limiter: &Limiter{Algorithm: TokenBucket{Rate: 3, Per: time.Hour}}})
+	kernel.AddControllers(&account{})
+
+	recorder := serve(kernel, "GET", "/profile", "10.0.0.1:1234")
+	if recorder.Code != 200 || recorder.Header().Get("RateLimit-Limit") != "3" || recorder.Header().Get("RateLimit-Remaining") != "2" {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+	if policy := recorder.Header().Get("RateLimit-Policy"); policy != "3;w=3600;burst=3" {
+		t.Errorf("unexpected policy %q", policy)
+	}
+
+	// the route limit is more restrictive than the global one
+	recorder = serve(kernel, "POST", "/login", "10.0.0.1:1234")
+	if recorder.Code != 200 || recorder.Header().Get("RateLimit-Limit") != "1" || recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+	recorder = serve(kernel, "POST", "/login", "10.0.0.1:1234")
+	if retryAfter, _ := strconv.Atoi(recorder.Header().Get("Retry-After")); recorder.Code != 429 || retryAfter <= 0 || retryAfter > 3600 {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+
+	// the global limit is exhausted by the 3 requests
+	if recorder = serve(kernel, "GET", "/profile", "10.0.0.1:1234"); recorder.Code != 429 {
+		t.Errorf("expected 429, got %d", recorder.Code)
+	}
+	if recorder = serve(kernel, "GET", "/profile", "10.0.0.2:1234"); recorder.Code != 200 {
+		t.Errorf("other clients should not be limited, got %d", recorder.Code)
+	}
+}
+
+func TestLimiter_GlobalAcrossRoutes(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{Limiter: &Limiter{Algorithm: TokenBucket{Rate: 2, Per: time.Hour}}})
+	kernel.AddControllers(&account{})
+	kernel.AddHandlerFunc("GET", "/news", func(c *cloudy.Context) {
+		c.WriteString("news")
+	})
+
+	if recorder := serve(kernel, "GET", "/news", "10.0.0.1:1234"); recorder.Code != 200 {
+		t.Fatalf("unexpected response %d", recorder.Code)
+	}
+	if recorder := serve(kernel, "GET", "/profile", "10.0.0.1:1234"); recorder.Code != 200 || recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("the global quota is not shared by the routes %d %v", recorder.Code, recorder.Header())
+	}
+	if recorder := serve(kernel, "GET", "/news", "10.0.0.1:1234"); recorder.Code != 429 {
+		t.Errorf("expected 429, got %d", recorder.Code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Algorithm) (Result, error) {
+	return Result{}, errors.New("backend down")
+}
+
+func TestLimiter_KeysAndErrors(t *testing.T) {
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&session.Component{
+		Manager: session.New(time.Hour, time.Hour, file.New(t.TempDir()), session.GobSessionEncoder{}, session.RandGenerator{}),
+	})
+	kernel.AddHandlerFunc("GET", "/session", func(c *cloudy.Context) {
+		c.WriteString("ok")
+	}, &Limiter{Algorithm: TokenBucket{Rate: 1, Per: time.Hour}, Key: FirstKey(BySession, ByIP)})
+	kernel.AddHandlerFunc("GET", "/anonymous", func(c *cloudy.Context) {
+		c.WriteString("ok")
+	}, &Limiter{Algorithm: TokenBucket{Rate: 1, Per: time.Hour}, Key: ByUser(func(c *cloudy.Context) string { return "" })})
+	kernel.AddHandlerFunc("GET", "/down", func(c *cloudy.Context) {
+		c.WriteString("ok")
+	}, &Limiter{Algorithm: TokenBucket{Rate: 1, Per: time.Hour}, Store: failingStore{}})
+	kernel.AddHandlerFunc("GET", "/closed", func(c *cloudy.Context) {
+		c.WriteString("ok")
+	}, &Limiter{Algorithm: TokenBucket{Rate: 1, Per: time.Hour}, Store: failingStore{}, FailClosed: true})
+
+	// each request without cookie gets a new session
+	for i := 0; i < 2; i++ {
+		if recorder := serve(kernel, "GET", "/session", "10.0.0.1:1"); recorder.Code != 200 {
+			t.Errorf("request %d: unexpected %d", i, recorder.Code)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if recorder := serve(kernel, "GET", "/anonymous", "10.0.0.1:1"); recorder.Code != 200 || recorder.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("request %d: requests without key should not be limited, got %d", i, recorder.Code)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if recorder := serve(kernel, "GET", "/down", "10.0.0.1:1"); recorder.Code != 200 {
+			t.Errorf("request %d: store errors should allow the request, got %d", i, recorder.Code)
+		}
+	}
+	if recorder := serve(kernel, "GET", "/closed", "10.0.0.1:1"); recorder.Code != 503 {
+		t.Errorf("store errors should refuse the request with FailClosed, got %d", recorder.Code)
+	}
+}
+
+func TestComponent_InvalidAlgorithm(t *testing.T) {
+	for _, algorithm := range []Algorithm{
+		nil,
+		TokenBucket{Per: time.Minute},
+		TokenBucket{Rate: 10},
+		TokenBucket{Rate: -1, Per: time.Minute},
+		SlidingWindow{Window: time.Minute},
+		SlidingWindow{Limit: 10},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%#v: expected Bootstrap to panic", algorithm)
+				}
+			}()
+			cloudy.NewKernel().AddComponents(&Component{Limiter: &Limiter{Algorithm: algorithm}})
+		}()
+	}
+}
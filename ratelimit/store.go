@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ratelimit
+
+import (
+	"context"
+	"github.com/CloudyKit/cloudy"
+	"hash/maphash"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var StoreType = reflect.TypeOf((*Store)(nil)).Elem()
+
+// GetStore returns the store of the registry, nil when there is none
+func GetStore(registry cloudy.Registry) Store {
+	store, _ := registry.LoadType(StoreType).(Store)
+	return store
+}
+
+// Store keeps the state of the keys, external backends implement Store running the algorithm
+// atomically in the backend, ex: a redis script for TokenBucket and SlidingWindow
+type Store interface {
+	Take(ctx context.Context, key string, algorithm Algorithm) (Result, error)
+}
+
+// DefaultShards is the number of shards of NewMemoryStore(0)
+const DefaultShards = 32
+
+// MemoryStore keeps the state in process, the keys are spread in shards locked independently and
+// the state of idle keys is dropped once the TTL of the algorithm passes
+type MemoryStore struct {
+	seed   maphash.Seed
+	shards []memoryShard
+	now    func() time.Time
+}
+
+type memoryShard struct {
+	mx      sync.Mutex
+	entries map[string]*memoryEntry
+	sweepAt time.Time
+}
+
+type memoryEntry struct {
+	state     interface{}
+	expiresAt time.Time
+}
+
+// sweepEvery is the interval between the sweeps of the expired keys of a shard
+const sweepEvery = time.Minute
+
+// NewMemoryStore creates a store with shards shards, 0 means DefaultShards
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	store := &MemoryStore{seed: maphash.MakeSeed(), shards: make([]memoryShard, shards), now: time.Now}
+	for i := range store.shards {
+		store.shards[i].entries = make(map[string]*memoryEntry)
+	}
+	return store
+}
+
+func (store *MemoryStore) Take(_ context.Context, key string, algorithm Algorithm) (Result, error) {
+	now := store.now()
+	shard := &store.shards[maphash.String(store.seed, key)%uint64(len(store.shards))]
+
+	shard.mx.Lock()
+	defer shard.mx.Unlock()
+
+	if now.After(shard.sweepAt) {
+		for name, entry := range shard.entries {
+			if now.After(entry.expiresAt) {
+				delete(shard.entries, name)
+			}
+		}
+		shard.sweepAt = now.Add(sweepEvery)
+	}
+
+	entry := shard.entries[key]
+	if entry == nil {
+		entry = &memoryEntry{}
+		shard.entries[key] = entry
+	} else if now.After(entry.expiresAt) {
+		entry.state = nil
+	}
+
+	var result Result
+	entry.state, result = algorithm.Take(entry.state, now)
+	entry.expiresAt = now.Add(algorithm.TTL())
+	return result, nil
+}
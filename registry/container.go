@@ -77,6 +77,14 @@ func (r *Registry) Container() Interface {
 	return r
 }
 
+// Root returns the registry at the top of the parents of r, the registry itself when it has no parent
+func (r *Registry) Root() *Registry {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
 // Injectable marks the type as field to be injectable while searching for fields to inject
 func Injectable(v ...interface{}) uint {
 	for i := 0; i < len(v); i++ {
@@ -208,6 +216,33 @@ func (r *Registry) Fork() Interface {
 	return nil
 }
 
+// Inherit makes parent the parent of r, values not found in r are looked up in parent, this is
+// the same relationship created by parent.Fork but for a registry already created and configured,
+// inheriting again the same parent has no effect
+func (r *Registry) Inherit(parent Interface) error {
+	parentRegistry, ok := parent.Container().(*Registry)
+	if !ok {
+		return fmt.Errorf("registry.Inherit: unsupported parent registry %T", parent)
+	}
+	if r.parent == parentRegistry {
+		return nil
+	}
+	if r.parent != nil {
+		return errors.New("registry.Inherit: the registry already has a parent")
+	}
+	for p := parentRegistry; p != nil; p = p.parent {
+		if p == r {
+			return errors.New("registry.Inherit: the parent inherits from the registry")
+		}
+	}
+	if atomic.LoadInt64(&parentRegistry.references) < 0 {
+		return errors.New("registry.Inherit: invoking inherit in a context already recycled")
+	}
+	atomic.AddInt64(&parentRegistry.references, 1)
+	r.parent = parentRegistry
+	return nil
+}
+
 // resolveType search's for value of type typ, walking the context tree from the current to the top parent looking for the value with type typ
 func (r *Registry) resolveType(typ reflect.Type) (val interface{}) {
 	for {
@@ -263,15 +298,16 @@ func (r *Registry) LoadType(typ reflect.Type) (val interface{}) {
 // is waiting all children to end
 func (r *Registry) Dispose() int64 {
 
-	// check if this is the last active reference
-	atomic.AddInt64(&r.references, -1)
+	// check if this is the last active reference, the registry can be reused once finalized so
+	// the counter is not read again
+	references := atomic.AddInt64(&r.references, -1)
 
-	if r.references == -1 {
+	if references == -1 {
 		r.finalize()
-	} else if r.references < -1 {
-		panic(fmt.Errorf("Inválid reference counting expected value is -1 got %v", r.references))
+	} else if references < -1 {
+		panic(fmt.Errorf("Inválid reference counting expected value is -1 got %v", references))
 	}
-	return r.references
+	return references
 }
 
 var err = errors.New("scope.registry.EndForce: requested that at this point all references to this context are previous cleared")
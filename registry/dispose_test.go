@@ -0,0 +1,71 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package registry
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type countingDisposer struct {
+	count *int64
+}
+
+func (d countingDisposer) Dispose() {
+	atomic.AddInt64(d.count, 1)
+}
+
+func TestDisposeConcurrent(t *testing.T) {
+	const children = 16
+
+	for i := 0; i < 200; i++ {
+		var finalized int64
+
+		parent := New()
+		parent.WithValues(countingDisposer{count: &finalized})
+
+		forks := make([]Interface, children)
+		for j := range forks {
+			forks[j] = parent.Fork()
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(children + 1)
+		for _, fork := range forks {
+			go func(fork Interface) {
+				defer wg.Done()
+				fork.Dispose()
+			}(fork)
+		}
+		go func() {
+			defer wg.Done()
+			parent.Dispose()
+		}()
+		wg.Wait()
+
+		if finalized != 1 {
+			t.Fatalf("expected the parent to be finalized once, got %d", finalized)
+		}
+	}
+}
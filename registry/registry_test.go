@@ -74,7 +74,7 @@ func TestDiDone(t *testing.T) {
 	if context.references != 0 {
 		t.Fatal("Inválid reference counting ", context.references)
 	}
-	var childContext = context.Fork()
+	var childContext = context.Fork().(*Registry)
 	if context.references != 1 {
 		t.Fatal("Inválid reference counting ", context.references)
 	}
@@ -25,6 +25,8 @@ package cloudy
 import (
 	"github.com/CloudyKit/cloudy/registry"
 	"github.com/CloudyKit/router"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -49,3 +51,35 @@ func TestContext_Advance(t *testing.T) {
 		t.Errorf("Not all handlers executed: want 5 got %v", counter)
 	}
 }
+
+func TestContext_Intercept(t *testing.T) {
+	kernel := NewKernel()
+
+	var calls []string
+	kernel.AddMiddlewareFunc(func(c *Context) {
+		c.Intercept(func(c *Context, handler Handler, next func()) {
+			calls = append(calls, "outer")
+			next()
+		})
+		c.Intercept(func(c *Context, handler Handler, next func()) {
+			calls = append(calls, "inner")
+			last := !c.HasNext()
+			next()
+			if last {
+				calls = append(calls, "last")
+			}
+		})
+		c.Next()
+	}, func(c *Context) {
+		calls = append(calls, "middleware")
+		c.Next()
+	})
+	kernel.AddHandlerFunc("GET", "/", func(c *Context) {
+		calls = append(calls, "handler")
+	})
+
+	kernel.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got := strings.Join(calls, ","); got != "outer,inner,middleware,outer,inner,handler,last" {
+		t.Fatalf("unexpected calls %s", got)
+	}
+}
@@ -0,0 +1,136 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"log/slog"
+	"net/http"
+	"reflect"
+)
+
+// RequestIDHeader is the header carrying the request id
+const RequestIDHeader = "X-Request-ID"
+
+var (
+	RequestIDType  = reflect.TypeOf(RequestID(""))
+	HTTPClientType = reflect.TypeOf((*http.Client)(nil))
+)
+
+// RequestID identifies a request, see RequestIDMiddleware
+type RequestID string
+
+// GetRequestID returns the id of the request, an empty string is returned when the request
+// was not identified by RequestIDMiddleware
+func GetRequestID(cdi Registry) string {
+	id, _ := cdi.LoadType(RequestIDType).(RequestID)
+	return string(id)
+}
+
+// GetLogger returns a log.Logger writing with level info to the structured logger of the
+// registry, inside a request the lines carry the attributes of the request, see GetSlog
+func GetLogger(cdi Registry) *log.Logger {
+	return slog.NewLogLogger(GetSlog(cdi).Handler(), slog.LevelInfo)
+}
+
+// GetHTTPClient returns the http client available in the registry or http.DefaultClient, inside
+// a request identified by RequestIDMiddleware the client sends the request id in the requests
+func GetHTTPClient(cdi Registry) *http.Client {
+	if client, _ := cdi.LoadType(HTTPClientType).(*http.Client); client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
+
+// RequestIDMiddleware identifies the request with the X-Request-ID header sent by the client or
+// with a generated id when the header is missing or invalid, generate is used to create ids, when
+// nil random ids are generated. The id is set in Context.RequestID, in the registry as RequestID,
+// and in the response header. The loggers and the http client loaded from the registry in the
+// request carry the id, see GetSlog, GetLogger and GetHTTPClient.
+//
+//	kernel.AddMiddleware(cloudy.RequestIDMiddleware(nil))
+func RequestIDMiddleware(generate func() string) HandlerFunc {
+	if generate == nil {
+		generate = newRequestID
+	}
+	return func(c *Context) {
+		id := c.Request.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = generate()
+		}
+
+		c.RequestID = id
+		c.Response.Header().Set(RequestIDHeader, id)
+		c.Registry.WithTypeAndValue(RequestIDType, RequestID(id))
+
+		// the client of the parent registry is wrapped when used
+		client := GetHTTPClient(c.Registry)
+		c.Registry.WithTypeAndProviderFunc(HTTPClientType, func(Registry) interface{} {
+			requestClient := *client
+			requestClient.Transport = &requestIDTransport{id: id, base: client.Transport}
+			return &requestClient
+		})
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts ids up to 128 visible ascii characters, other ids could be used to
+// inject data into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// requestIDTransport sets the request id header in the outgoing requests
+type requestIDTransport struct {
+	id   string
+	base http.RoundTripper
+}
+
+func (transport *requestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := transport.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if r.Header.Get(RequestIDHeader) == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set(RequestIDHeader, transport.id)
+	}
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,93 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(RequestIDHeader)))
+	}))
+	defer upstream.Close()
+
+	var logs bytes.Buffer
+	kernel := NewKernel()
+	kernel.Registry.WithTypeAndValue(SlogType, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})))
+	kernel.AddMiddleware(RequestIDMiddleware(func() string { return "generated" }))
+	kernel.AddHandlerFunc("GET", "/", func(c *Context) {
+		GetLogger(c.Registry).Println("handling")
+
+		response, err := GetHTTPClient(c.Registry).Get(upstream.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer response.Body.Close()
+		var propagated bytes.Buffer
+		propagated.ReadFrom(response.Body)
+
+		c.WriteString(c.RequestID + " " + GetRequestID(c.Registry) + " " + propagated.String())
+	})
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/", nil)
+		if id != "" {
+			request.Header.Set(RequestIDHeader, id)
+		}
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := serve("abc-123")
+	if recorder.Body.String() != "abc-123 abc-123 abc-123" || recorder.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Fatalf("unexpected response %q %v", recorder.Body.String(), recorder.Header())
+	}
+	if logs.String() != "level=INFO msg=handling route=\"\" method=GET path=/ request_id=abc-123\n" {
+		t.Fatalf("unexpected log %q", logs.String())
+	}
+
+	for _, id := range []string{"", "bad id", "line\nbreak", strings.Repeat("a", 129)} {
+		if recorder := serve(id); recorder.Header().Get(RequestIDHeader) != "generated" {
+			t.Errorf("%q: unexpected id %q", id, recorder.Header().Get(RequestIDHeader))
+		}
+	}
+
+	if id := newRequestID(); len(id) != 32 || id == newRequestID() {
+		t.Fatalf("unexpected generated id %q", id)
+	}
+}
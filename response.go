@@ -0,0 +1,201 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps the http.ResponseWriter of the request, the status and the size of the
+// response are recorded. WriteHeader is sent once, later calls are ignored. The wrapper implements
+// http.Flusher, http.Hijacker and io.ReaderFrom when the wrapped writer implements them.
+type ResponseWriter interface {
+	http.ResponseWriter
+	// Status returns the status sent, 0 while the header is not written
+	Status() int
+	// Size returns the number of bytes of the body written
+	Size() int64
+	// Written reports if the header was written or the connection was hijacked
+	Written() bool
+	// Before adds a func called before the header is written with the status being sent,
+	// funcs run in the reverse order they were added
+	Before(fn func(status int))
+	// Unwrap returns the wrapped writer, see http.ResponseController
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int64
+	hijacked bool
+	before   []func(status int)
+}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	*w = responseWriter{ResponseWriter: writer, before: w.before[:0]}
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int64 {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.status != 0 || w.hijacked
+}
+
+func (w *responseWriter) Before(fn func(status int)) {
+	w.before = append(w.before, fn)
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.Written() {
+		return
+	}
+	// informational headers can be followed by the final header
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	for i := len(w.before) - 1; i >= 0; i-- {
+		w.before[i](status)
+	}
+	w.before = w.before[:0]
+
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) flush() {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) readFrom(r io.Reader) (int64, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	w.size += n
+	return n, err
+}
+
+type (
+	flusher    struct{ w *responseWriter }
+	hijacker   struct{ w *responseWriter }
+	readerFrom struct{ w *responseWriter }
+)
+
+func (f flusher) Flush() {
+	f.w.flush()
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.w.hijack()
+}
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	return r.w.readFrom(src)
+}
+
+// wrap returns w with the optional interfaces implemented by the wrapped writer
+func (w *responseWriter) wrap() ResponseWriter {
+	_, isFlusher := w.ResponseWriter.(http.Flusher)
+	_, isHijacker := w.ResponseWriter.(http.Hijacker)
+	_, isReaderFrom := w.ResponseWriter.(io.ReaderFrom)
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+			readerFrom
+		}{w, flusher{w}, hijacker{w}, readerFrom{w}}
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			flusher
+			hijacker
+		}{w, flusher{w}, hijacker{w}}
+	case isFlusher && isReaderFrom:
+		return struct {
+			*responseWriter
+			flusher
+			readerFrom
+		}{w, flusher{w}, readerFrom{w}}
+	case isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			hijacker
+			readerFrom
+		}{w, hijacker{w}, readerFrom{w}}
+	case isFlusher:
+		return struct {
+			*responseWriter
+			flusher
+		}{w, flusher{w}}
+	case isHijacker:
+		return struct {
+			*responseWriter
+			hijacker
+		}{w, hijacker{w}}
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			readerFrom
+		}{w, readerFrom{w}}
+	}
+	return w
+}
@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	kernel := NewKernel()
+	var order []string
+	kernel.AddMiddlewareFunc(func(c *Context) {
+		c.Writer().Before(func(status int) {
+			order = append(order, "first")
+		})
+		c.Writer().Before(func(status int) {
+			order = append(order, "second")
+			c.Response.Header().Set("X-Status", http.StatusText(status))
+		})
+		c.Next()
+
+		writer := c.Writer()
+		if !writer.Written() || writer.Status() != http.StatusCreated || writer.Size() != int64(len("{\"ok\":true}\n")) {
+			t.Errorf("unexpected writer state %v %d %d", writer.Written(), writer.Status(), writer.Size())
+		}
+	})
+	kernel.AddHandlerFunc("POST", "/", func(c *Context) {
+		if c.Writer().Written() {
+			t.Error("written before the handler")
+		}
+		c.Response.WriteHeader(http.StatusCreated)
+		_ = c.SendJSONStatusCode(http.StatusOK, map[string]bool{"ok": true})
+	})
+
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("POST", "/", nil))
+	if recorder.Code != http.StatusCreated || recorder.Header().Get("X-Status") != "Created" || recorder.Body.String() != "{\"ok\":true}\n" {
+		t.Fatalf("unexpected response %d %v %q", recorder.Code, recorder.Header(), recorder.Body.String())
+	}
+	if strings.Join(order, ",") != "second,first" {
+		t.Fatalf("unexpected hooks order %v", order)
+	}
+}
+
+func TestResponseWriter_Interfaces(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerFunc("GET", "/", func(c *Context) {
+		_, isFlusher := c.Response.(http.Flusher)
+		_, isHijacker := c.Response.(http.Hijacker)
+		readerFrom, isReaderFrom := c.Response.(io.ReaderFrom)
+		if !isFlusher || !isHijacker || !isReaderFrom {
+			t.Errorf("interfaces lost: flusher %v hijacker %v reader from %v", isFlusher, isHijacker, isReaderFrom)
+			return
+		}
+		readerFrom.ReadFrom(strings.NewReader("streamed"))
+		if c.Writer().Size() != 8 || c.Writer().Status() != http.StatusOK {
+			t.Errorf("unexpected writer state %d %d", c.Writer().Size(), c.Writer().Status())
+		}
+	})
+
+	server := httptest.NewServer(kernel)
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if body, _ := io.ReadAll(response.Body); string(body) != "streamed" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	// httptest.ResponseRecorder implements only http.Flusher
+	writer := &responseWriter{ResponseWriter: httptest.NewRecorder()}
+	wrapped := writer.wrap()
+	if _, ok := wrapped.(http.Flusher); !ok {
+		t.Fatal("flusher lost")
+	}
+	if _, ok := wrapped.(http.Hijacker); ok {
+		t.Fatal("unexpected hijacker")
+	}
+	wrapped.(http.Flusher).Flush()
+	if !wrapped.Written() || wrapped.Status() != http.StatusOK {
+		t.Fatal("flush must write the header")
+	}
+}
@@ -0,0 +1,211 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Route describes a route added to the kernel
+type Route struct {
+	Method      string   `json:"method"`
+	Host        string   `json:"host,omitempty"` // Host pattern of routes added with Kernel.Host
+	Path        string   `json:"path"`
+	Name        string   `json:"name,omitempty"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares,omitempty"`
+	Source      string   `json:"source,omitempty"` // Source file:line where the route was added
+
+	handlers []Handler
+}
+
+// Handlers returns the middlewares and the handler of the route
+func (route Route) Handlers() []Handler {
+	return route.handlers
+}
+
+type routeTable struct {
+	mx      sync.RWMutex
+	routes  []Route
+	methods []string // methods sorted methods of the routes of any host and the methods served by mounts
+}
+
+// kernelDir is the directory of this package, frames in this directory are skipped
+// while looking for the source of a route
+var kernelDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+func (table *routeTable) add(method, path, name string, handlers []Handler) Route {
+	route := Route{
+		Method:  method,
+		Path:    path,
+		Name:    name,
+		Handler: HandlerName(handlers[len(handlers)-1]),
+		Source:  routeSource(),
+
+		handlers: handlers,
+	}
+	for _, middleware := range handlers[:len(handlers)-1] {
+		route.Middlewares = append(route.Middlewares, HandlerName(middleware))
+	}
+
+	table.append(route)
+	return route
+}
+
+// append adds routes to the table, the method set is replaced when a route brings a new method
+func (table *routeTable) append(routes ...Route) {
+	table.mx.Lock()
+	defer table.mx.Unlock()
+
+	if table.methods == nil {
+		table.methods = append([]string(nil), mountMethods...)
+		sort.Strings(table.methods)
+	}
+	for _, route := range routes {
+		table.routes = append(table.routes, route)
+		if route.Host != "" {
+			continue
+		}
+		if index := sort.SearchStrings(table.methods, route.Method); index == len(table.methods) || table.methods[index] != route.Method {
+			// readers keep using the previous set, see allowedMethods
+			methods := make([]string, 0, len(table.methods)+1)
+			methods = append(methods, table.methods[:index]...)
+			methods = append(methods, route.Method)
+			table.methods = append(methods, table.methods[index:]...)
+		}
+	}
+}
+
+// methodSet returns the sorted methods with routes, the slice must not be modified
+func (table *routeTable) methodSet() []string {
+	table.mx.RLock()
+	defer table.mx.RUnlock()
+	if table.methods == nil {
+		return sortedMountMethods
+	}
+	return table.methods
+}
+
+// Routes returns the routes added to the kernel, routes added in groups or components
+// forked from this kernel are included
+func (kernel *Kernel) Routes() []Route {
+	kernel.routes.mx.RLock()
+	defer kernel.routes.mx.RUnlock()
+	return append([]Route(nil), kernel.routes.routes...)
+}
+
+// MatchRoute returns the route the router runs for method and path, routes of mounted kernels
+// are matched with the mount path, routes of other hosts are not matched
+func (kernel *Kernel) MatchRoute(method, path string) (Route, bool) {
+	handler, params := kernel.Router.FindRoute(method, path)
+	if handler == nil {
+		return Route{}, false
+	}
+	request := (&http.Request{Method: method, URL: &url.URL{Path: path}, Header: http.Header{}}).WithContext(context.Background())
+	lookup := &routeLookup{}
+	handler(lookup, request, params)
+	return lookup.route, lookup.found
+}
+
+// routeLookup is passed as the writer to the handlers of the router by MatchRoute, the handler
+// of a route stores the route instead of serving the request
+type routeLookup struct {
+	route Route
+	found bool
+}
+
+func (*routeLookup) Header() http.Header         { return http.Header{} }
+func (*routeLookup) Write(p []byte) (int, error) { return len(p), nil }
+func (*routeLookup) WriteHeader(int)             {}
+
+// RoutesHandler returns a handler that sends the route table as json,
+// ex: kernel.AddHandler("GET", "/debug/routes", kernel.RoutesHandler())
+func (kernel *Kernel) RoutesHandler() HandlerFunc {
+	return func(c *Context) {
+		_ = c.SendJSON(kernel.Routes())
+	}
+}
+
+// RoutesEnv is set by the cloudy routes command when it runs an application in package main
+const RoutesEnv = "CLOUDY_ROUTES"
+
+// RoutesHook writes the route table as json to stdout and exits when the process was started
+// by the cloudy routes command, RunServer and RunServerTLS call it, apps in package main
+// serving the kernel in other ways call it once the routes are added
+func (kernel *Kernel) RoutesHook() {
+	if os.Getenv(RoutesEnv) == "" {
+		return
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(kernel.Routes()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// HandlerName returns the name of the handler, ex: github.com/app/controllers.(*Users).Show
+func HandlerName(handler Handler) string {
+	switch handler := handler.(type) {
+	case HandlerFunc:
+		return funcName(reflect.ValueOf(handler))
+	case *controllerHandler:
+		return funcName(handler.funcValue)
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+func funcName(fn reflect.Value) string {
+	if f := runtime.FuncForPC(fn.Pointer()); f != nil {
+		return strings.TrimSuffix(f.Name(), "-fm")
+	}
+	return fn.Type().String()
+}
+
+// routeSource returns the file:line of the first caller outside this package
+func routeSource() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != kernelDir || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
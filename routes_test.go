@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKernel_Routes(t *testing.T) {
+	kernel := NewKernel()
+	kernel.Group("/api", func(api *Kernel) {
+		api.NamePrefix = "api."
+		api.AddControllers(&groupUsers{})
+	}, middlewareTrace("api"))
+	kernel.AddHandlerFunc("GET|POST", "/ping", func(c *Context) {})
+
+	routes := kernel.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("want 3 routes got %d", len(routes))
+	}
+
+	users := routes[0]
+	if users.Method != "GET" || users.Path != "/api/users/:id" || users.Name != "api.Users.Show" {
+		t.Fatalf("unexpected route %+v", users)
+	}
+	if !strings.HasSuffix(users.Handler, "(*groupUsers).Show") || len(users.Middlewares) != 1 {
+		t.Fatalf("unexpected handler chain %+v", users)
+	}
+	if !strings.Contains(users.Source, "group_test.go:") {
+		t.Fatalf("unexpected source %q", users.Source)
+	}
+
+	if routes[1].Method != "GET" || routes[2].Method != "POST" || routes[2].Path != "/ping" {
+		t.Fatalf("unexpected routes %+v", routes[1:])
+	}
+}
+
+func TestKernel_MatchRoute(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddHandlerName("users.show", "GET", "/users/:id", HandlerFunc(func(c *Context) {}), middlewareTrace("show"))
+	kernel.AddHandlerName("users.new", "GET", "/users/new", HandlerFunc(func(c *Context) {}))
+	kernel.AddHandlerName("files", "GET", "/files/*path", HandlerFunc(func(c *Context) {}))
+
+	tests := map[string]string{
+		"/users/5":      "users.show",
+		"/users/new":    "users.new",
+		"/files/a/b.js": "files",
+	}
+	for path, name := range tests {
+		route, ok := kernel.MatchRoute("GET", path)
+		if !ok || route.Name != name {
+			t.Errorf("%s: want %q got %q", path, name, route.Name)
+		}
+	}
+
+	if route, _ := kernel.MatchRoute("GET", "/users/5"); len(route.Handlers()) != 2 {
+		t.Errorf("unexpected handlers %v", route.Handlers())
+	}
+	for _, path := range []string{"/users", "/users/5/posts", "/other"} {
+		if route, ok := kernel.MatchRoute("GET", path); ok {
+			t.Errorf("%s: unexpected match %q", path, route.Name)
+		}
+	}
+	if _, ok := kernel.MatchRoute("POST", "/users/5"); ok {
+		t.Errorf("unexpected match for POST")
+	}
+
+	billing := NewKernel()
+	billing.AddHandlerName("invoices.show", "GET", "/invoices/:id", HandlerFunc(func(c *Context) {}))
+	kernel.Mount("/billing", billing)
+	kernel.MountHandler("/debug", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("MatchRoute should not serve mounted handlers")
+	}))
+	if route, ok := kernel.MatchRoute("GET", "/billing/invoices/7"); !ok || route.Name != "invoices.show" || route.Path != "/billing/invoices/:id" {
+		t.Errorf("unexpected mounted route %+v", route)
+	}
+	if route, ok := kernel.MatchRoute("GET", "/debug/vars"); ok {
+		t.Errorf("unexpected match %+v", route)
+	}
+}
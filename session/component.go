@@ -24,7 +24,6 @@ package session
 
 import (
 	"github.com/CloudyKit/cloudy"
-	"log"
 	"net/http"
 	"reflect"
 )
@@ -56,7 +55,7 @@ func (component *Component) Handle(ctx *cloudy.Context) {
 		}
 		err := component.Manager.Open(ctx.Registry, readedcookie.Value, &s.data) //todo: use this error message here can be helpful
 		if err != nil {
-			log.Println("Session read err:", err.Error())
+			cloudy.GetSlog(ctx.Registry).Error("session read", "error", err)
 		}
 	}
 
@@ -88,7 +87,7 @@ func (component *Component) Handle(ctx *cloudy.Context) {
 	_sessionPool.Put(s)
 
 	if err != nil {
-		log.Println("Session write err:", err.Error())
+		cloudy.GetSlog(ctx.Registry).Error("session write", "error", err)
 	}
 }
 
@@ -23,11 +23,54 @@
 package session
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"github.com/CloudyKit/cloudy"
 	"github.com/CloudyKit/cloudy/utils/concurrent"
+	"io"
+	"reflect"
 	"time"
 )
 
+var StoreObserverType = reflect.TypeOf(StoreObserver(nil))
+
+// StoreObserver is called when the manager uses the store, operation is open, save or remove,
+// done is called with the result when the operation finishes, ex: metrics and tracing
+type StoreObserver func(registry cloudy.Registry, operation string) (done func(err error))
+
+// ObserveStore adds observer to the store observers of the registry, managers used with the
+// registry or with registries forked from it call the observer
+func ObserveStore(registry cloudy.Registry, observer StoreObserver) {
+	if current, _ := registry.LoadType(StoreObserverType).(StoreObserver); current != nil {
+		next := observer
+		observer = func(registry cloudy.Registry, operation string) func(err error) {
+			currentDone, nextDone := current(registry, operation), next(registry, operation)
+			return func(err error) {
+				if nextDone != nil {
+					nextDone(err)
+				}
+				if currentDone != nil {
+					currentDone(err)
+				}
+			}
+		}
+	}
+	registry.WithTypeAndValue(StoreObserverType, observer)
+}
+
+// observeStore calls the store observers of the registry, the returned func is never nil
+func observeStore(registry cloudy.Registry, operation string) func(err error) {
+	if registry != nil {
+		if observer, _ := registry.LoadType(StoreObserverType).(StoreObserver); observer != nil {
+			if done := observer(registry, operation); done != nil {
+				return done
+			}
+		}
+	}
+	return func(error) {}
+}
+
 type CookieOptions struct {
 	Name   string
 	Path   string
@@ -65,8 +108,11 @@ func (manager *Manager) gcgoroutine() {
 }
 
 // Open load stored session and un serialize the stored data into dst
-func (manager *Manager) Open(ctx cloudy.Registry, sessionName string, dst interface{}) error {
+func (manager *Manager) Open(ctx cloudy.Registry, sessionName string, dst interface{}) (err error) {
 	defer manager.kMX.Lock(sessionName).Unlock()
+	done := observeStore(ctx, "open")
+	defer func() { done(err) }()
+
 	reader, err := manager.Store.Reader(ctx, sessionName, time.Now().Add(-manager.Duration))
 	if err == nil && reader != nil {
 		err = manager.Serializer.Decode(dst, reader)
@@ -78,8 +124,11 @@ func (manager *Manager) Open(ctx cloudy.Registry, sessionName string, dst interf
 }
 
 // Save save the session
-func (manager *Manager) Save(ctx cloudy.Registry, sessionName string, session interface{}) error {
+func (manager *Manager) Save(ctx cloudy.Registry, sessionName string, session interface{}) (err error) {
 	defer manager.kMX.Lock(sessionName).Unlock()
+	done := observeStore(ctx, "save")
+	defer func() { done(err) }()
+
 	writer, err := manager.Store.Writer(ctx, sessionName)
 	if err == nil && writer != nil {
 		err = manager.Serializer.Encode(session, writer)
@@ -91,7 +140,72 @@ func (manager *Manager) Save(ctx cloudy.Registry, sessionName string, session in
 }
 
 // Remove remove the session
-func (manager *Manager) Remove(ctx cloudy.Registry, sessionName string) error {
+func (manager *Manager) Remove(ctx cloudy.Registry, sessionName string) (err error) {
 	defer manager.kMX.Lock(sessionName).Unlock()
+	done := observeStore(ctx, "remove")
+	defer func() { done(err) }()
+
 	return manager.Store.Remove(ctx, sessionName)
 }
+
+// StoreChecker returns a StoreProbe of the store of the manager, the probe is a health.Checker, ex:
+//
+//	health.AddChecker(kernel.Registry, "session", manager.StoreChecker(kernel.Registry))
+func (manager *Manager) StoreChecker(registry cloudy.Registry) StoreProbe {
+	return StoreProbe{manager: manager, registry: registry}
+}
+
+// StoreProbe checks the store writing, reading and removing a probe key, the store is used
+// directly so the probe is not seen by the store observers
+type StoreProbe struct {
+	manager  *Manager
+	registry cloudy.Registry
+}
+
+func (probe StoreProbe) Check(ctx context.Context) error {
+	manager, registry := probe.manager, probe.registry
+	key := "health-probe-" + RandGenerator{}.Generate("", "")
+
+	writer, err := manager.Store.Writer(registry, key)
+	if err != nil {
+		return err
+	}
+	if writer == nil {
+		return errors.New("session: store returned no writer for the probe")
+	}
+	_, err = io.WriteString(writer, key)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = manager.readProbe(ctx, registry, key)
+	if removeErr := manager.Store.Remove(registry, key); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+func (manager *Manager) readProbe(ctx context.Context, registry cloudy.Registry, probe string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	reader, err := manager.Store.Reader(registry, probe, time.Now().Add(-time.Minute))
+	if err != nil {
+		return err
+	}
+	if reader == nil {
+		return errors.New("session: probe not found in the store")
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, []byte(probe)) {
+		return errors.New("session: probe read back does not match the written value")
+	}
+	return nil
+}
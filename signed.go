@@ -0,0 +1,50 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"github.com/CloudyKit/cloudy/link"
+	"net/http"
+)
+
+// SignedURLMiddleware rejects with 403 the requests which url was tampered or expired, urls are
+// signed with link.Signed, when signer is nil link.DefaultSigner is used.
+//
+//	mx.BindAction("GET", "/downloads/:id", "Download", cloudy.SignedURLMiddleware(nil))
+func SignedURLMiddleware(signer *link.Signer) HandlerFunc {
+	return func(c *Context) {
+		s := signer
+		if s == nil {
+			s = link.DefaultSigner
+		}
+
+		switch err := s.Verify(c.Request); err {
+		case nil:
+			c.Next()
+		case link.ErrExpiredSignature:
+			_ = c.SendTextWithStatus(http.StatusForbidden, "link expired")
+		default:
+			_ = c.SendTextWithStatus(http.StatusForbidden, "invalid signature")
+		}
+	}
+}
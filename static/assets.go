@@ -0,0 +1,57 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package static
+
+import (
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/link"
+	"strings"
+)
+
+// ImmutableCacheControl is sent with fingerprinted assets, the content of a fingerprinted url never changes
+const ImmutableCacheControl = "public, max-age=31536000, immutable"
+
+// AssetsComponent serves the files of an asset manifest under the manifest prefix and provides
+// the manifest in the registry, making link.Asset available in controllers and views.
+//
+//	manifest, err := link.NewAssetManifest("/static", os.DirFS("./public"))
+//	kernel.AddComponents(&static.AssetsComponent{Manifest: manifest})
+type AssetsComponent struct {
+	Manifest *link.AssetManifest
+}
+
+func (component *AssetsComponent) Bootstrap(kernel *cloudy.Kernel) {
+	kernel.Registry.WithTypeAndValue(link.AssetManifestType, component.Manifest)
+	kernel.AddHandlerName("static.Assets", "GET|HEAD", strings.TrimSuffix(component.Manifest.Prefix, "/")+"/*file", component)
+}
+
+// Handle serves fingerprinted names with immutable cache headers, plain names are revalidated
+func (component *AssetsComponent) Handle(c *cloudy.Context) {
+	name, fingerprinted := component.Manifest.Resolve(c.GetURLParameter("file"))
+	if fingerprinted {
+		c.Response.Header().Set("Cache-Control", ImmutableCacheControl)
+	} else {
+		c.Response.Header().Set("Cache-Control", "no-cache")
+	}
+	serveFS(c.Response, c.Request, component.Manifest.FS, name)
+}
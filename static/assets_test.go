@@ -0,0 +1,68 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package static
+
+import (
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/link"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssetsComponent(t *testing.T) {
+	files := fstest.MapFS{
+		"css/app.css": &fstest.MapFile{Data: []byte("body{}")},
+	}
+
+	manifest, err := link.NewAssetManifest("/static", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&AssetsComponent{Manifest: manifest})
+
+	assetURL := link.Asset(kernel.Registry, "css/app.css")
+	if !strings.HasPrefix(assetURL, "/static/css/app.") || !strings.HasSuffix(assetURL, ".css") || assetURL == "/static/css/app.css" {
+		t.Fatalf("unexpected asset url %q", assetURL)
+	}
+
+	recorder := httptest.NewRecorder()
+	kernel.Router.ServeHTTP(recorder, httptest.NewRequest("GET", assetURL, nil))
+	if recorder.Body.String() != "body{}" || recorder.Header().Get("Cache-Control") != ImmutableCacheControl {
+		t.Fatalf("unexpected response %d %q %q", recorder.Code, recorder.Body.String(), recorder.Header().Get("Cache-Control"))
+	}
+
+	recorder = httptest.NewRecorder()
+	kernel.Router.ServeHTTP(recorder, httptest.NewRequest("GET", "/static/css/app.css", nil))
+	if recorder.Body.String() != "body{}" || recorder.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("plain name: unexpected response %d %q", recorder.Code, recorder.Header().Get("Cache-Control"))
+	}
+
+	dev := link.NewDevAssetManifest("/static", files)
+	if got := dev.URL("css/app.css"); got != "/static/css/app.css" {
+		t.Fatalf("dev manifest: unexpected url %q", got)
+	}
+}
@@ -0,0 +1,256 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Component serves the files of FS under Prefix.
+//
+//	kernel.AddComponents(&static.Component{Prefix: "/public", FS: os.DirFS("./public"), Precompressed: true})
+type Component struct {
+	Prefix string // Prefix url prefix where the files are served, ex: /public
+	FS     fs.FS  // FS holds the files
+
+	Index         string // Index file served for directories, default index.html
+	NoIndex       bool   // NoIndex disables serving the Index file for directories
+	Browse        bool   // Browse enables listing directories without an Index file
+	Precompressed bool   // Precompressed serves name.gz when the client accepts gzip
+	SPA           bool   // SPA serves the root Index file for not found paths without extension
+	CacheControl  string // CacheControl header sent with the files
+}
+
+func (component *Component) index() string {
+	if component.Index == "" {
+		return "index.html"
+	}
+	return component.Index
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	kernel.AddHandlerName("static", "GET|HEAD", strings.TrimSuffix(component.Prefix, "/")+"/*file", component)
+}
+
+func (component *Component) Handle(c *cloudy.Context) {
+	w, r := c.Response, c.Request
+
+	name, ok := cleanName(c.GetURLParameter("file"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	stat, err := fs.Stat(component.FS, name)
+	if err != nil {
+		if component.SPA && path.Ext(name) == "" && !component.NoIndex {
+			component.serve(w, r, component.index())
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	if stat.IsDir() {
+		// relative links in the index or in the listing require the trailing slash, the redirect is
+		// relative as the path seen by the client can have a prefix stripped, ex: Kernel.Mount
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			location := path.Base(r.URL.Path) + "/"
+			if r.URL.RawQuery != "" {
+				location += "?" + r.URL.RawQuery
+			}
+			w.Header().Set("Location", location)
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+
+		if !component.NoIndex {
+			index := path.Join(name, component.index())
+			if indexStat, err := fs.Stat(component.FS, index); err == nil && !indexStat.IsDir() {
+				component.serve(w, r, index)
+				return
+			}
+		}
+
+		if component.Browse {
+			listDirectory(w, component.FS, name)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	component.serve(w, r, name)
+}
+
+func (component *Component) serve(w http.ResponseWriter, r *http.Request, name string) {
+	if component.CacheControl != "" {
+		w.Header().Set("Cache-Control", component.CacheControl)
+	}
+
+	if component.Precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsEncoding(r, "gzip") {
+			if _, err := fs.Stat(component.FS, name+".gz"); err == nil {
+				if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+					w.Header().Set("Content-Type", ctype)
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				serveFS(w, r, component.FS, name+".gz")
+				return
+			}
+		}
+	}
+
+	serveFS(w, r, component.FS, name)
+}
+
+// cleanName converts the url parameter into a fs.FS name, names escaping the root are rejected
+func cleanName(name string) (string, bool) {
+	if strings.Contains(name, "\\") || strings.Contains(name, "\x00") {
+		return "", false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+// acceptsEncoding reports if the Accept-Encoding header accepts encoding with a non zero quality
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, accepted := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+			if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+				continue
+			}
+			if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+				if quality, err := strconv.ParseFloat(q, 64); err == nil && quality == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func listDirectory(w http.ResponseWriter, fsys fs.FS, name string) {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		http.Error(w, "error reading directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintln(w, "<pre>")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	fmt.Fprintln(w, "</pre>")
+}
+
+// serveFS serves the file name from fsys with ETag and Last-Modified, ranges and conditional
+// requests are handled by http.ServeContent, directories and invalid names are not found
+func serveFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, isSeeker := file.(io.ReadSeeker)
+	if !isSeeker {
+		b, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	if w.Header().Get("ETag") == "" {
+		etag, err := fileETag(stat, content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), content)
+}
+
+// fileETag returns the ETag of the file from the modification time and the size, files without
+// modification time, ex: embed.FS, are tagged with a hash of the content
+func fileETag(stat fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !stat.ModTime().IsZero() {
+		return fmt.Sprintf("\"%x-%x\"", stat.ModTime().UnixNano(), stat.Size()), nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return "\"" + hex.EncodeToString(hash.Sum(nil))[:16] + "\"", nil
+}
@@ -0,0 +1,152 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package static
+
+import (
+	"github.com/CloudyKit/cloudy"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestComponent(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":     &fstest.MapFile{Data: []byte("root index")},
+		"app.js":         &fstest.MapFile{Data: []byte("plain javascript")},
+		"app.js.gz":      &fstest.MapFile{Data: []byte("gzipped javascript")},
+		"docs/readme.md": &fstest.MapFile{Data: []byte("0123456789")},
+		"secret.txt":     &fstest.MapFile{Data: []byte("secret")},
+	}
+
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{Prefix: "/public", FS: files, Precompressed: true, SPA: true, Browse: true})
+
+	get := func(target string, headers ...string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", target, nil)
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		recorder := httptest.NewRecorder()
+		kernel.Router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	if recorder := get("/public/app.js"); recorder.Body.String() != "plain javascript" || recorder.Header().Get("ETag") == "" {
+		t.Fatalf("unexpected response %d %q etag %q", recorder.Code, recorder.Body.String(), recorder.Header().Get("ETag"))
+	}
+
+	recorder := get("/public/app.js", "Accept-Encoding", "br, gzip")
+	if recorder.Body.String() != "gzipped javascript" || recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("precompressed: unexpected response %q %q", recorder.Body.String(), recorder.Header().Get("Content-Encoding"))
+	}
+	if ctype := recorder.Header().Get("Content-Type"); ctype != "text/javascript; charset=utf-8" {
+		t.Fatalf("precompressed: unexpected content type %q", ctype)
+	}
+	if recorder := get("/public/app.js", "Accept-Encoding", "gzip;q=0"); recorder.Body.String() != "plain javascript" {
+		t.Fatalf("gzip;q=0 served %q", recorder.Body.String())
+	}
+
+	etag := get("/public/docs/readme.md").Header().Get("ETag")
+	if recorder := get("/public/docs/readme.md", "If-None-Match", etag); recorder.Code != 304 {
+		t.Fatalf("If-None-Match: unexpected status %d", recorder.Code)
+	}
+	if recorder := get("/public/docs/readme.md", "Range", "bytes=2-4"); recorder.Code != 206 || recorder.Body.String() != "234" {
+		t.Fatalf("range: unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	if recorder := get("/public/docs"); recorder.Code != 301 || recorder.Header().Get("Location") != "docs/" {
+		t.Fatalf("directory: unexpected redirect %d %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+	if recorder := get("/public/docs?v=1"); recorder.Header().Get("Location") != "docs/?v=1" {
+		t.Fatalf("directory: the query was lost %q", recorder.Header().Get("Location"))
+	}
+	if recorder := get("/public/docs/"); recorder.Code != 200 || recorder.Body.String() != "<pre>\n<a href=\"readme.md\">readme.md</a>\n</pre>\n" {
+		t.Fatalf("listing: unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	if recorder := get("/public/"); recorder.Body.String() != "root index" {
+		t.Fatalf("index: unexpected response %q", recorder.Body.String())
+	}
+
+	if recorder := get("/public/users/5"); recorder.Body.String() != "root index" {
+		t.Fatalf("spa fallback: unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	if recorder := get("/public/missing.css"); recorder.Code != 404 {
+		t.Fatalf("missing asset: unexpected status %d", recorder.Code)
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":            ".",
+		"a/b.txt":     "a/b.txt",
+		"a//b.txt":    "a/b.txt",
+		"./a/./b.txt": "a/b.txt",
+	} {
+		if got, ok := cleanName(name); !ok || got != expected {
+			t.Errorf("cleanName(%q) = %q, %v; expected %q", name, got, ok, expected)
+		}
+	}
+	for _, name := range []string{"../etc/passwd", "a/../../b", "a\\..\\b", "a\x00b"} {
+		if got, ok := cleanName(name); ok {
+			t.Errorf("cleanName(%q) = %q, expected rejection", name, got)
+		}
+	}
+}
+
+func TestComponent_Mounted(t *testing.T) {
+	assets := cloudy.NewKernel()
+	assets.AddComponents(&Component{Prefix: "/public", FS: fstest.MapFS{"docs/index.html": &fstest.MapFile{Data: []byte("docs")}}})
+	kernel := cloudy.NewKernel()
+	kernel.Mount("/assets", assets)
+
+	target, _ := url.Parse("http://example.com/assets/public/docs?v=1")
+	recorder := httptest.NewRecorder()
+	kernel.ServeHTTP(recorder, httptest.NewRequest("GET", target.String(), nil))
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil || recorder.Code != 301 {
+		t.Fatalf("unexpected redirect %d %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+	if redirected := target.ResolveReference(location).String(); redirected != "http://example.com/assets/public/docs/?v=1" {
+		t.Fatalf("unexpected redirect %q", redirected)
+	}
+}
+
+func TestComponent_ETagWithoutModTime(t *testing.T) {
+	etag := func(data string, modTime time.Time) string {
+		kernel := cloudy.NewKernel()
+		kernel.AddComponents(&Component{FS: fstest.MapFS{"app.js": &fstest.MapFile{Data: []byte(data), ModTime: modTime}}})
+		recorder := httptest.NewRecorder()
+		kernel.ServeHTTP(recorder, httptest.NewRequest("GET", "/app.js", nil))
+		return recorder.Header().Get("ETag")
+	}
+
+	// embedded files have no modification time, a redeploy with an edit keeping the size must change the etag
+	if before, after := etag("var v = 1", time.Time{}), etag("var v = 2", time.Time{}); before == "" || before == after {
+		t.Errorf("the etag should follow the content, got %q and %q", before, after)
+	}
+	if before, after := etag("var v = 1", time.Unix(1, 0)), etag("var v = 1", time.Unix(2, 0)); before == after {
+		t.Errorf("the etag should follow the modification time, got %q and %q", before, after)
+	}
+}
@@ -0,0 +1,130 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package trace
+
+import (
+	"context"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/event"
+	"github.com/CloudyKit/cloudy/session"
+	"net/http"
+	"strings"
+)
+
+// Component opens a server span for each request, continuing the trace of the traceparent
+// header, and child spans around the middlewares and the action of the route, the events
+// dispatched and the session store operations. Middlewares added before the component are not
+// traced, add the component first.
+type Component struct {
+	Tracer *Tracer // Tracer default the tracer of the registry, see GetTracer
+}
+
+func (component *Component) Bootstrap(kernel *cloudy.Kernel) {
+	if component.Tracer == nil {
+		component.Tracer = GetTracer(kernel.Registry)
+	}
+	if component.Tracer == nil {
+		panic("trace: Component.Tracer is nil and the registry has no Tracer, ex: &trace.Component{Tracer: trace.NewTracer(exporter)}")
+	}
+	kernel.Registry.WithTypeAndValue(TracerType, component.Tracer)
+
+	if dispatcher, ok := kernel.Registry.LoadType(event.EmitterType).(*event.Dispatcher); ok {
+		dispatcher.Observe(func(ctx context.Context, eventName string) (context.Context, func(bool, error)) {
+			ctx, span := Start(ctx, "event "+eventName, String("event.name", eventName))
+			return ctx, func(canceled bool, err error) {
+				span.SetAttributes(Bool("event.canceled", canceled))
+				span.SetError(err)
+				span.End()
+			}
+		})
+	}
+
+	session.ObserveStore(kernel.Registry, func(registry cloudy.Registry, operation string) func(error) {
+		ctx, _ := registry.LoadType(cloudy.GoContextType).(context.Context)
+		if ctx == nil {
+			return nil
+		}
+		_, span := Start(ctx, "session "+operation, String("session.operation", operation))
+		return func(err error) {
+			span.SetError(err)
+			span.End()
+		}
+	})
+
+	kernel.Root().AddMiddleware(component)
+}
+
+func (component *Component) Handle(c *cloudy.Context) {
+	r := c.Request
+	ctx := r.Context()
+	if remote, ok := Extract(r.Header); ok {
+		ctx = ContextWithRemoteSpanContext(ctx, remote)
+	}
+
+	ctx, span := component.Tracer.StartKind(ctx, r.Method+" "+c.Name, KindServer,
+		String("http.method", r.Method),
+		String("http.target", r.URL.Path),
+		String("http.route", c.Name),
+	)
+	defer span.End()
+
+	c.Request = r.WithContext(ctx)
+	c.Intercept(intercept)
+	c.Next()
+
+	status := c.Writer().Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttributes(Int("http.status_code", status))
+	if status >= 500 {
+		span.SetError(errorStatus(status))
+	}
+}
+
+// intercept opens a span around the handler, the span is available in the request context
+func intercept(c *cloudy.Context, handler cloudy.Handler, next func()) {
+	kind := "middleware"
+	if !c.HasNext() {
+		kind = "action"
+	}
+
+	name := cloudy.HandlerName(handler)
+	if i := strings.LastIndexByte(name, '/'); i != -1 {
+		name = name[i+1:]
+	}
+
+	parent := c.Request.Context()
+	ctx, span := Start(parent, kind+" "+name, String("cloudy.handler", cloudy.HandlerName(handler)))
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+	next()
+	c.Request = c.Request.WithContext(parent)
+}
+
+type errorStatus int
+
+func (status errorStatus) Error() string {
+	return http.StatusText(int(status))
+}
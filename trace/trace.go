@@ -0,0 +1,392 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package trace records spans of the work done while handling requests. The Component opens spans
+// around the request, each middleware and action, the events dispatched and the session store,
+// spans are sent to an Exporter, see MemoryExporter and OTLPExporter.
+//
+//	tracer := trace.NewTracer(&trace.OTLPExporter{ServiceName: "shop"})
+//	defer tracer.Shutdown(context.Background())
+//	kernel.AddComponents(&trace.Component{Tracer: tracer})
+//
+//	ctx, span := trace.Start(c.GoContext(), "load cart")
+//	defer span.End()
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/CloudyKit/cloudy"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var TracerType = reflect.TypeOf((*Tracer)(nil))
+
+// GetTracer returns the tracer available in the registry
+func GetTracer(cdi cloudy.Registry) *Tracer {
+	tracer, _ := cdi.LoadType(TracerType).(*Tracer)
+	return tracer
+}
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id TraceID) IsValid() bool  { return id != TraceID{} }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+func (id SpanID) IsValid() bool   { return id != SpanID{} }
+
+// SpanContext identifies a span, local or received from another service, see Extract
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind describes the relationship of the span with the rest of the trace
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+)
+
+// Attribute is a key value pair describing a span, values are strings, integers, floats or booleans
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute        { return Attribute{key, value} }
+func Int(key string, value int) Attribute       { return Attribute{key, int64(value)} }
+func Float(key string, value float64) Attribute { return Attribute{key, value} }
+func Bool(key string, value bool) Attribute     { return Attribute{key, value} }
+
+// SpanData is a finished span, as received by the exporters
+type SpanData struct {
+	Name string
+	Kind SpanKind
+	SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Error      string
+}
+
+// Span is an operation of a trace, a nil Span is valid and records nothing
+type Span struct {
+	mx     sync.Mutex
+	data   SpanData
+	ended  bool
+	tracer *Tracer
+}
+
+// SpanContext returns the identity of the span
+func (span *Span) SpanContext() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+	return span.data.SpanContext
+}
+
+// SetAttributes adds attributes to the span, attributes with the same key are replaced
+func (span *Span) SetAttributes(attributes ...Attribute) {
+	if span == nil {
+		return
+	}
+	span.mx.Lock()
+	defer span.mx.Unlock()
+	for _, attribute := range attributes {
+		replaced := false
+		for i := range span.data.Attributes {
+			if span.data.Attributes[i].Key == attribute.Key {
+				span.data.Attributes[i] = attribute
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			span.data.Attributes = append(span.data.Attributes, attribute)
+		}
+	}
+}
+
+// SetError marks the span as failed, nil errors are ignored
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.mx.Lock()
+	span.data.Error = err.Error()
+	span.mx.Unlock()
+}
+
+// End finishes the span, sampled spans are sent to the exporter of the tracer, only the first
+// call has effect
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	span.mx.Lock()
+	if span.ended {
+		span.mx.Unlock()
+		return
+	}
+	span.ended = true
+	span.data.End = time.Now()
+	data := span.data
+	span.mx.Unlock()
+
+	if data.Sampled {
+		span.tracer.enqueue(data)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the span carried by ctx, nil when ctx has no span
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithSpan returns a copy of ctx carrying span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx carrying the span of another service, spans
+// started with the returned context continue the trace of sc, see Extract
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the span in ctx, or the remote span context
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a span child of the span in ctx, with the tracer of that span, when ctx has no
+// span nothing is recorded and the returned span is nil
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.start(ctx, name, KindInternal, attributes)
+}
+
+// Tracer creates spans and sends the finished spans to the Exporter in batches from a background
+// goroutine, a batch is sent when BatchSize spans are finished or every Interval. Spans finished
+// while QueueSize spans wait to be sent are dropped, ending a span never waits for the exporter.
+// Call Shutdown before exiting to send the spans left in the queue.
+type Tracer struct {
+	Exporter  Exporter
+	BatchSize int           // BatchSize default 512
+	QueueSize int           // QueueSize default 2048
+	Interval  time.Duration // Interval default 5s
+	Timeout   time.Duration // Timeout of each export default 10s
+	OnError   func(error)   // OnError receives the export errors, by default errors are discarded
+
+	mx      sync.Mutex
+	queue   []SpanData
+	dropped int
+
+	once     sync.Once
+	stopOnce sync.Once
+	notify   chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{Exporter: exporter}
+}
+
+// Start starts a span child of the span or of the remote span context in ctx, spans without
+// parent start a new trace
+func (tracer *Tracer) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return tracer.start(ctx, name, KindInternal, attributes)
+}
+
+// StartKind works as Start but the span is created with kind, ex: KindServer for incoming requests
+func (tracer *Tracer) StartKind(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	return tracer.start(ctx, name, kind, attributes)
+}
+
+func (tracer *Tracer) start(ctx context.Context, name string, kind SpanKind, attributes []Attribute) (context.Context, *Span) {
+	span := &Span{tracer: tracer}
+	span.data = SpanData{Name: name, Kind: kind, Start: time.Now()}
+	span.data.Sampled = true
+
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.data.TraceID = parent.TraceID
+		span.data.Parent = parent.SpanID
+		span.data.Sampled = parent.Sampled
+	} else {
+		_, _ = rand.Read(span.data.TraceID[:])
+	}
+	_, _ = rand.Read(span.data.SpanID[:])
+
+	if len(attributes) > 0 {
+		span.data.Attributes = append([]Attribute(nil), attributes...)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (tracer *Tracer) batchSize() int {
+	if tracer.BatchSize <= 0 {
+		return 512
+	}
+	return tracer.BatchSize
+}
+
+func (tracer *Tracer) queueSize() int {
+	if tracer.QueueSize <= 0 {
+		return 2048
+	}
+	return tracer.QueueSize
+}
+
+func (tracer *Tracer) enqueue(data SpanData) {
+	tracer.once.Do(tracer.startExport)
+
+	tracer.mx.Lock()
+	if len(tracer.queue) >= tracer.queueSize() {
+		tracer.dropped++
+		tracer.mx.Unlock()
+		return
+	}
+	tracer.queue = append(tracer.queue, data)
+	full := len(tracer.queue) >= tracer.batchSize()
+	tracer.mx.Unlock()
+
+	if full {
+		select {
+		case tracer.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (tracer *Tracer) startExport() {
+	tracer.notify = make(chan struct{}, 1)
+	tracer.stop = make(chan struct{})
+	tracer.stopped = make(chan struct{})
+	go tracer.run()
+}
+
+// run sends the queue when a batch is full or every Interval until Shutdown
+func (tracer *Tracer) run() {
+	defer close(tracer.stopped)
+
+	interval, timeout := tracer.Interval, tracer.Timeout
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tracer.stop:
+			return
+		case <-tracer.notify:
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := tracer.Flush(ctx)
+		cancel()
+		if err != nil && tracer.OnError != nil {
+			tracer.OnError(err)
+		}
+	}
+}
+
+// Flush sends the finished spans to the exporter in batches of BatchSize, the first error stops
+// the flush and the spans of the failed batch are discarded
+func (tracer *Tracer) Flush(ctx context.Context) error {
+	tracer.mx.Lock()
+	var err error
+	if tracer.dropped > 0 {
+		err = fmt.Errorf("trace: %d spans dropped, the queue is full", tracer.dropped)
+		tracer.dropped = 0
+	}
+	tracer.mx.Unlock()
+
+	for {
+		tracer.mx.Lock()
+		spans := tracer.queue
+		if batchSize := tracer.batchSize(); len(spans) > batchSize {
+			spans = spans[:batchSize:batchSize]
+		}
+		tracer.queue = tracer.queue[len(spans):]
+		tracer.mx.Unlock()
+
+		if len(spans) == 0 {
+			return err
+		}
+		if tracer.Exporter == nil {
+			continue
+		}
+		if exportErr := tracer.Exporter.Export(ctx, spans); exportErr != nil {
+			return errors.Join(exportErr, err)
+		}
+	}
+}
+
+// Shutdown stops the background goroutine and sends the spans left in the queue, spans finished
+// after Shutdown are sent only by Flush
+func (tracer *Tracer) Shutdown(ctx context.Context) error {
+	tracer.once.Do(tracer.startExport)
+	tracer.stopOnce.Do(func() {
+		close(tracer.stop)
+	})
+	select {
+	case <-tracer.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return tracer.Flush(ctx)
+}
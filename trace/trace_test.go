@@ -0,0 +1,275 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/CloudyKit/cloudy"
+	"github.com/CloudyKit/cloudy/event"
+	"github.com/CloudyKit/cloudy/session"
+	"github.com/CloudyKit/cloudy/session/store/file"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractInject(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	sc, ok := Extract(header)
+	if !ok || !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected span context %+v %v", sc, ok)
+	}
+
+	injected := http.Header{}
+	Inject(ContextWithRemoteSpanContext(context.Background(), sc), injected)
+	if injected.Get(TraceparentHeader) != header.Get(TraceparentHeader) {
+		t.Fatalf("unexpected traceparent %q", injected.Get(TraceparentHeader))
+	}
+
+	for _, invalid := range []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		header.Set(TraceparentHeader, invalid)
+		if _, ok := Extract(header); ok {
+			t.Errorf("%q: want invalid", invalid)
+		}
+	}
+}
+
+type cartUpdated struct {
+	event.Event
+}
+
+func TestComponent(t *testing.T) {
+	exporter := new(MemoryExporter)
+	tracer := NewTracer(exporter)
+
+	kernel := cloudy.NewKernel()
+	kernel.AddComponents(&Component{Tracer: tracer}, &session.Component{
+		Manager: session.New(time.Hour, time.Hour, file.New(t.TempDir()), session.GobSessionEncoder{}, session.RandGenerator{}),
+	})
+	kernel.Subscribe("cart.updated", func(e *cartUpdated) {
+		_, span := Start(e.Context(), "recalculate")
+		span.End()
+	})
+	kernel.AddHandlerName("cart.update", "POST", "/cart", cloudy.HandlerFunc(func(c *cloudy.Context) {
+		event.Dispatch(c.Registry, "cart.updated", new(cartUpdated))
+		c.Response.WriteHeader(500)
+	}))
+
+	request := httptest.NewRequest("POST", "/cart", nil)
+	request.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	kernel.ServeHTTP(httptest.NewRecorder(), request)
+	if len(exporter.Spans()) != 0 {
+		t.Fatal("spans should be exported in the background")
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]SpanData{}
+	for _, span := range exporter.Spans() {
+		spans[strings.SplitN(span.Name, " ", 2)[0]] = span
+		if span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("%s: unexpected trace id %s", span.Name, span.TraceID)
+		}
+	}
+
+	server, middleware, action := spans["POST"], spans["middleware"], spans["action"]
+	if server.Kind != KindServer || server.Parent.String() != "00f067aa0ba902b7" || server.Error != "Internal Server Error" {
+		t.Fatalf("unexpected server span %+v", server)
+	}
+	if !strings.Contains(middleware.Name, "*session.Component") || middleware.Parent != server.SpanID {
+		t.Fatalf("unexpected middleware span %+v", middleware)
+	}
+	if action.Parent != middleware.SpanID {
+		t.Fatalf("unexpected action span %+v", action)
+	}
+	if spans["event"].Name != "event cart.updated" || spans["event"].Parent != action.SpanID {
+		t.Fatalf("unexpected event span %+v", spans["event"])
+	}
+	if spans["recalculate"].Parent != spans["event"].SpanID {
+		t.Fatalf("unexpected handler span %+v", spans["recalculate"])
+	}
+	if spans["session"].Name != "session save" || spans["session"].Parent != middleware.SpanID {
+		t.Fatalf("unexpected session span %+v", spans["session"])
+	}
+}
+
+func TestComponent_DefaultTracer(t *testing.T) {
+	exporter := new(MemoryExporter)
+	kernel := cloudy.NewKernel()
+	kernel.Registry.WithTypeAndValue(TracerType, NewTracer(exporter))
+	kernel.AddComponents(&Component{})
+	kernel.AddHandlerFunc("GET", "/", func(c *cloudy.Context) {})
+
+	kernel.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err := GetTracer(kernel.Registry).Flush(context.Background()); err != nil || len(exporter.Spans()) == 0 {
+		t.Fatalf("the tracer of the registry was not used, %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic without tracer")
+		}
+	}()
+	cloudy.NewKernel().AddComponents(&Component{})
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var received map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer(&OTLPExporter{Endpoint: collector.URL + "/v1/traces", ServiceName: "shop"})
+	tracer.OnError = func(err error) {
+		t.Error(err)
+	}
+
+	ctx, root := tracer.Start(context.Background(), "checkout", Int("items", 2))
+	_, child := Start(ctx, "charge")
+	child.SetError(errors.New("card declined"))
+	child.End()
+	root.End()
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	resourceSpans := received["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	service := resourceSpans["resource"].(map[string]interface{})["attributes"].([]interface{})[0].(map[string]interface{})
+	if service["key"] != "service.name" || service["value"].(map[string]interface{})["stringValue"] != "shop" {
+		t.Fatalf("unexpected resource %v", resourceSpans["resource"])
+	}
+
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans got %d", len(spans))
+	}
+	charge, checkout := spans[0].(map[string]interface{}), spans[1].(map[string]interface{})
+	if charge["parentSpanId"] != checkout["spanId"] || charge["traceId"] != root.SpanContext().TraceID.String() {
+		t.Fatalf("unexpected span relationship %v %v", charge, checkout)
+	}
+	if status := charge["status"].(map[string]interface{}); status["code"] != float64(2) || status["message"] != "card declined" {
+		t.Fatalf("unexpected status %v", status)
+	}
+	if attribute := checkout["attributes"].([]interface{})[0].(map[string]interface{}); attribute["value"].(map[string]interface{})["intValue"] != "2" {
+		t.Fatalf("unexpected attribute %v", attribute)
+	}
+
+	collector.Close()
+	_, failed := tracer.Start(context.Background(), "offline")
+	failed.End()
+	if err := tracer.Flush(context.Background()); err == nil {
+		t.Fatal("expected the export error")
+	}
+}
+
+// blockingExporter blocks the exports until release is closed
+type blockingExporter struct {
+	MemoryExporter
+	release chan struct{}
+}
+
+func (exporter *blockingExporter) Export(ctx context.Context, spans []SpanData) error {
+	select {
+	case <-exporter.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return exporter.MemoryExporter.Export(ctx, spans)
+}
+
+func TestTracer_Background(t *testing.T) {
+	exporter := &blockingExporter{release: make(chan struct{})}
+	tracer := &Tracer{Exporter: exporter, BatchSize: 2, QueueSize: 4, Interval: time.Hour}
+	errs := make(chan error, 1)
+	tracer.OnError = func(err error) {
+		errs <- err
+	}
+
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		_, span := tracer.Start(context.Background(), "request")
+		span.End()
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("ending spans waited for the exporter %v", elapsed)
+	}
+
+	close(exporter.release)
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "spans dropped") {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the dropped spans were not reported")
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the first batch was taken by the background export before the queue filled up
+	if spans := len(exporter.Spans()); spans < 4 || spans > 6 {
+		t.Fatalf("unexpected exported spans %d", spans)
+	}
+}
+
+func TestTracer_Timeout(t *testing.T) {
+	exporter := &blockingExporter{release: make(chan struct{})}
+	tracer := &Tracer{Exporter: exporter, BatchSize: 1, Timeout: 20 * time.Millisecond}
+	errs := make(chan error, 1)
+	tracer.OnError = func(err error) {
+		errs <- err
+	}
+
+	_, span := tracer.Start(context.Background(), "request")
+	span.End()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the export did not time out")
+	}
+	tracer.Shutdown(context.Background())
+}
@@ -25,35 +25,82 @@ package cloudy
 import (
 	"fmt"
 	"github.com/CloudyKit/cloudy/link"
+	"net/url"
 )
 
+// MapURLGen maps url names to route patterns, ex: "Users.Show" => "/users/:id"
 type MapURLGen map[string]string
 type ControllerURLGen struct {
 	urlGen MapURLGen
 	id     string
+	prefix string
 	Parent link.URLGen
 }
 
+// URL generates the url named dst, see Build, an empty string is returned when the url can't be built
 func (urlGen *ControllerURLGen) URL(dst string, v ...interface{}) string {
+	generatedURL, _ := urlGen.Build(dst, v...)
+	return generatedURL
+}
+
+// Build generates the url named dst, names are looked up relative to the controller, then relative
+// to the group where the controller was added and then in the parent url generator.
+func (urlGen *ControllerURLGen) Build(dst string, v ...interface{}) (string, error) {
+
+	if pattern, ok := urlGen.urlGen[urlGen.id+dst]; ok {
+		return link.Expand(pattern, v...)
+	}
 
-	if dst, ok := urlGen.urlGen[urlGen.id+dst]; ok {
-		return fmt.Sprintf(dst, v...)
+	// names relative to the group where the controller was added
+	if urlGen.prefix != "" {
+		if pattern, ok := urlGen.urlGen[urlGen.prefix+dst]; ok {
+			return link.Expand(pattern, v...)
+		}
 	}
 
-	if dst, ok := urlGen.urlGen[dst]; ok {
-		return fmt.Sprintf(dst, v...)
+	if pattern, ok := urlGen.urlGen[dst]; ok {
+		return link.Expand(pattern, v...)
 	}
 
 	if urlGen.Parent != nil {
-		return urlGen.Parent.URL(dst, v...)
+		if builder, ok := urlGen.Parent.(link.Builder); ok {
+			return builder.Build(dst, v...)
+		}
+		return urlGen.Parent.URL(dst, v...), nil
 	}
 
-	return fmt.Sprintf(dst, v...)
+	return expandResource(dst, v)
 }
 
+// URL generates the url named dst, see Build, an empty string is returned when the url can't be built
 func (urlGen MapURLGen) URL(dst string, v ...interface{}) string {
-	if dst, ok := urlGen[dst]; ok {
-		return fmt.Sprintf(dst, v...)
+	generatedURL, _ := urlGen.Build(dst, v...)
+	return generatedURL
+}
+
+// Build generates the url named dst filling the route parameters with v, v accepts positional
+// values, link.Params and link.Query, ex:
+//
+//	urlGen.Build("Users.Show", link.Params{"id": 5}, link.Query{"tab": "a b"}) => /users/5?tab=a+b
+func (urlGen MapURLGen) Build(dst string, v ...interface{}) (string, error) {
+	if pattern, ok := urlGen[dst]; ok {
+		return link.Expand(pattern, v...)
+	}
+	return expandResource(dst, v)
+}
+
+// expandResource handles resources that are not url names, resources used with named parameters
+// or query values are expanded as route patterns, otherwise the resource is used as format. The
+// arguments are a slice, URL and Build take url names and are not printf wrappers.
+func expandResource(resource string, args []interface{}) (string, error) {
+	if len(args) == 0 {
+		return resource, nil
+	}
+	for _, arg := range args {
+		switch arg.(type) {
+		case link.Params, link.Query, url.Values:
+			return link.Expand(resource, args...)
+		}
 	}
-	return fmt.Sprintf(dst, v...)
+	return fmt.Sprintf(resource, args...), nil
 }
@@ -0,0 +1,69 @@
+// MIT License
+//
+// Copyright (c) 2017 José Santos <henrique_1609@me.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cloudy
+
+import (
+	"github.com/CloudyKit/cloudy/link"
+	"testing"
+)
+
+func TestMapURLGen_Build(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddControllers(&groupUsers{})
+
+	got, err := kernel.URLGen.Build("Users.Show", link.Params{"id": "a b"}, link.Query{"tab": "a b"})
+	if err != nil || got != "/users/a%20b?tab=a+b" {
+		t.Fatalf("unexpected url %q, %v", got, err)
+	}
+
+	if _, err = kernel.URLGen.Build("Users.Show"); err == nil {
+		t.Fatal("expected missing parameter error")
+	}
+	if got = kernel.URLGen.URL("Users.Show", 1, 2); got != "" {
+		t.Fatalf("expected an empty url for extra parameters got %q", got)
+	}
+	if got = kernel.URLGen.URL("/static/app.css"); got != "/static/app.css" {
+		t.Fatalf("unexpected url %q", got)
+	}
+}
+
+type files struct{}
+
+func (f *files) Mx(mx *Mapper) {
+	mx.Name = "Files"
+	mx.BindAction("GET", "/files/*path", "Get")
+}
+
+func (f *files) Get() {}
+
+func TestMapURLGen_Wildcard(t *testing.T) {
+	kernel := NewKernel()
+	kernel.AddControllers(&files{})
+
+	// the value of the wildcard can start with a slash, as matched by the router
+	for _, path := range []string{"docs/read me.txt", "/docs/read me.txt"} {
+		if got := kernel.URLGen.URL("Files.Get", path); got != "/files/docs/read%20me.txt" {
+			t.Errorf("%q: unexpected url %q", path, got)
+		}
+	}
+}